@@ -0,0 +1,67 @@
+package walkrepo
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// GenerateAllowlist walks root, returning the content of a .gitignore which,
+// placed at the root of a copy of the tree in place of its own ignore files,
+// would admit exactly the entries the walk found: a "*" ignoring everything,
+// followed by an anchored negation re-including each walked directory and
+// file, in path order.
+func GenerateAllowlist(root string, opts ...Option) (string, error) {
+	root, opts, err := rootRelative(root, opts)
+	if err != nil {
+		return "", err
+	}
+	var lines []string
+	err = WalkRepo(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		line := "!/" + escapePattern(filepath.ToSlash(rel))
+		if info.IsDir() {
+			line += "/"
+		}
+		lines = append(lines, line)
+		return nil
+	}, opts...)
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(lines)
+
+	var b strings.Builder
+	b.WriteString("*\n")
+	for _, line := range lines {
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	return b.String(), nil
+}
+
+// escapePattern escapes the characters of the slash-separated path which
+// would otherwise be special in a pattern, so that the pattern matches only
+// the path itself.
+func escapePattern(path string) string {
+	var b strings.Builder
+	for _, r := range path {
+		if strings.ContainsRune(`\*?[`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	escaped := b.String()
+	// Trailing spaces are otherwise trimmed.
+	if strings.HasSuffix(escaped, " ") {
+		escaped = escaped[:len(escaped)-1] + `\ `
+	}
+	return escaped
+}
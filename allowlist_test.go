@@ -0,0 +1,52 @@
+package walkrepo
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGenerateAllowlist(t *testing.T) {
+	files := map[string]string{
+		"README.md":            "content",
+		"debug.log":            "content",
+		"src/main.go":          "content",
+		"src/main.log":         "content",
+		"src/gen/out.go":       "content",
+		"src/vendor/x/y.go":    "content",
+		"docs/a [1]*?.md":      "content",
+		"docs/trailing ":       "content",
+		"docs/#hash":           "content",
+		"docs/!bang":           "content",
+		"build/bin/tool":       "content",
+		"build/keep/notes.txt": "content",
+	}
+	ignores := map[string]string{
+		".gitignore":     "*.log\nbuild/*\n!build/keep/\n",
+		"src/.gitignore": "gen/\nvendor/\n",
+	}
+
+	root := t.TempDir()
+	writeTree(t, root, files)
+	writeTree(t, root, ignores)
+
+	allowlist, err := GenerateAllowlist(root)
+	if err != nil {
+		t.Fatalf("GenerateAllowlist() error = %v", err)
+	}
+	if !strings.HasPrefix(allowlist, "*\n!/") {
+		t.Errorf("GenerateAllowlist() = %q, want a leading \"*\" and negations", allowlist)
+	}
+
+	// Applied to a copy of the tree without its own ignore files, the
+	// allowlist admits the same entries.
+	replica := t.TempDir()
+	writeTree(t, replica, files)
+	writeTree(t, replica, map[string]string{".gitignore": allowlist})
+
+	want := walkedPaths(t, root)
+	if got := walkedPaths(t, replica); !reflect.DeepEqual(got, want) {
+		t.Errorf("allowlist admitted %v, want %v\nallowlist:\n%s", got, want, allowlist)
+	}
+	assertWalked(t, want, []string{"docs/a [1]*?.md", "docs/trailing ", "build/keep/notes.txt"}, []string{"src/gen", "build/bin"})
+}
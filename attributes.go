@@ -0,0 +1,40 @@
+package walkrepo
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/go-git/go-git/v5/plumbing/format/gitattributes"
+)
+
+// attributesFile is the name of the per-directory attributes file.
+const attributesFile = ".gitattributes"
+
+// attributesIn returns the attributes set by the .gitattributes file among
+// files, the entries of the directory dir, rooted at domain. As in git, macros
+// may only be defined at the root, which isRoot reports dir to be.
+func attributesIn(dir string, domain []string, files []fs.DirEntry, isRoot bool) ([]gitattributes.MatchAttribute, error) {
+	for _, file := range files {
+		if file.Name() != attributesFile || file.IsDir() {
+			continue
+		}
+		f, err := os.Open(filepath.Join(dir, file.Name()))
+		if os.IsNotExist(err) {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return gitattributes.ReadAttributes(f, domain, isRoot)
+	}
+	return nil, nil
+}
+
+// exportIgnored reports whether the attributes matched by m give path the
+// export-ignore attribute.
+func exportIgnored(m gitattributes.Matcher, path []string) bool {
+	attrs, _ := m.Match(path, []string{"export-ignore"})
+	attr, ok := attrs["export-ignore"]
+	return ok && attr.IsSet()
+}
@@ -0,0 +1,32 @@
+package walkrepo
+
+import "testing"
+
+func TestWithExportIgnore(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitattributes":     "/docs export-ignore\n*.md export-ignore\nkeep.md -export-ignore\n",
+		".gitignore":         "*.log\n",
+		"docs/guide.txt":     "content",
+		"src/docs/api.txt":   "content",
+		"src/.gitattributes": "fixtures export-ignore\n",
+		"src/fixtures/a":     "content",
+		"src/main.go":        "content",
+		"src/notes.md":       "content",
+		"src/debug.log":      "content",
+		"other/fixtures/b":   "content",
+		"README.md":          "content",
+		"keep.md":            "content",
+	})
+
+	// The expectations are those of git archive for the same tree.
+	walked := walkedPaths(t, root, WithExportIgnore(true))
+	assertWalked(t, walked,
+		[]string{".gitattributes", "keep.md", "other/fixtures/b", "src/.gitattributes", "src/docs/api.txt", "src/main.go"},
+		[]string{"docs", "docs/guide.txt", "src/fixtures", "src/fixtures/a", "src/notes.md", "README.md", "src/debug.log"},
+	)
+
+	// Without the option, attributes are disregarded.
+	walked = walkedPaths(t, root)
+	assertWalked(t, walked, []string{"docs/guide.txt", "src/fixtures/a", "README.md"}, []string{"src/debug.log"})
+}
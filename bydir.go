@@ -0,0 +1,47 @@
+package walkrepo
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// WalkRepoByDir walks root as WalkRepo does, calling fn once for each walked
+// directory, the root included, with the directory's non-ignored immediate
+// children. Directories are passed once all of their children have been
+// visited: in post-order, each after its subdirectories, or with BreadthFirst,
+// level by level. A directory whose children are all ignored is passed with
+// none.
+//
+// An error returned by fn aborts the walk, and is returned.
+func WalkRepoByDir(root string, fn func(dir string, entries []os.FileInfo) error, opts ...Option) error {
+	children := make(map[string][]os.FileInfo)
+	var fnErr error
+	exitHook := newConfig(opts).dirExitHook
+
+	opts = append(opts[:len(opts):len(opts)], WithDirExitHook(func(dir string) {
+		if fnErr == nil {
+			fnErr = fn(dir, children[dir])
+		}
+		delete(children, dir)
+		if exitHook != nil {
+			exitHook(dir)
+		}
+	}))
+	err := WalkRepo(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fnErr != nil {
+			// fn failed as a directory was exited; this is the first chance
+			// to abort the walk.
+			return fnErr
+		}
+		dir := filepath.Dir(path)
+		children[dir] = append(children[dir], info)
+		return nil
+	}, opts...)
+	if fnErr != nil {
+		return fnErr
+	}
+	return err
+}
@@ -0,0 +1,80 @@
+package walkrepo
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestWalkRepoByDir(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":      "*.log\nbuild/\n",
+		"a.txt":           "content",
+		"a.log":           "content",
+		"build/out.bin":   "content",
+		"sub/b.txt":       "content",
+		"sub/c.txt":       "content",
+		"sub/deep/d.txt":  "content",
+		"logs/only.log":   "content",
+		"sub/.gitignore":  "c.txt\n",
+		"sub/deep/e.log":  "content",
+		"sub/deep/f/g.md": "content",
+	})
+
+	got := make(map[string][]string)
+	var order []string
+	err := WalkRepoByDir(root, func(dir string, entries []os.FileInfo) error {
+		rel, _ := filepath.Rel(root, dir)
+		rel = filepath.ToSlash(rel)
+		if _, ok := got[rel]; ok {
+			t.Errorf("directory %s passed twice", rel)
+		}
+		names := []string{}
+		for _, info := range entries {
+			names = append(names, info.Name())
+		}
+		sort.Strings(names)
+		got[rel] = names
+		order = append(order, rel)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkRepoByDir() error = %v", err)
+	}
+
+	want := map[string][]string{
+		".":          {"a.txt", "logs", "sub"},
+		"logs":       {},
+		"sub":        {"b.txt", "deep"},
+		"sub/deep":   {"d.txt", "f"},
+		"sub/deep/f": {"g.md"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("WalkRepoByDir() passed %v, want %v", got, want)
+	}
+
+	// Each directory comes after its subdirectories.
+	index := make(map[string]int)
+	for i, dir := range order {
+		index[dir] = i
+	}
+	for dir := range want {
+		if parent := filepath.ToSlash(filepath.Dir(dir)); dir != "." && index[parent] < index[dir] {
+			t.Errorf("%s passed before its subdirectory %s", parent, dir)
+		}
+	}
+
+	errStop := errors.New("stop")
+	calls := 0
+	err = WalkRepoByDir(root, func(dir string, entries []os.FileInfo) error {
+		calls++
+		return errStop
+	})
+	if err != errStop || calls != 1 {
+		t.Errorf("WalkRepoByDir() with a failing fn = %v after %d calls, want %v after 1", err, calls, errStop)
+	}
+}
@@ -0,0 +1,36 @@
+package walkrepo
+
+import (
+	"context"
+	"os"
+)
+
+// WalkRepoChan walks root as WalkRepo does in a new goroutine, sending each
+// non-ignored entry on the returned entries channel, which is closed when the
+// walk ends. The walk's error, or nil, is then available on the returned error
+// channel.
+//
+// The entries channel is unbuffered unless WithChannelBuffer is given. Either
+// way, the walk blocks whenever the channel is full, so a slow consumer holds
+// the walk back rather than letting entries pile up. A consumer which stops
+// receiving early must cancel ctx, which ends the walk with ctx's error.
+func WalkRepoChan(ctx context.Context, root string, opts ...Option) (<-chan Entry, <-chan error) {
+	cfg := newConfig(opts)
+	entries := make(chan Entry, cfg.channelBuffer)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(entries)
+		errc <- WalkRepo(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			select {
+			case entries <- newEntry(cfg, path, info):
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}, opts...)
+	}()
+	return entries, errc
+}
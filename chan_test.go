@@ -0,0 +1,85 @@
+package walkrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWalkRepoChan(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{".gitignore": "*.log\n", "skip.log": "content"}
+	for i := 0; i < 50; i++ {
+		files[fmt.Sprintf("dir%d/f%d.txt", i%5, i)] = "content"
+	}
+	writeTree(t, root, files)
+
+	const buffer = 3
+	entries, errc := WalkRepoChan(context.Background(), root, WithChannelBuffer(buffer))
+	if cap(entries) != buffer {
+		t.Fatalf("entries channel has capacity %d, want %d", cap(entries), buffer)
+	}
+
+	// With no one receiving, the walk fills the buffer and then blocks.
+	deadline := time.Now().Add(time.Second)
+	for len(entries) < buffer && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := len(entries); n != buffer {
+		t.Errorf("%d entries buffered while the consumer was idle, want %d", n, buffer)
+	}
+	select {
+	case err := <-errc:
+		t.Fatalf("walk finished with %v while the consumer was idle", err)
+	default:
+	}
+
+	// A slow consumer still receives everything, never with the walk more
+	// than the buffer ahead.
+	walked := make(map[string]bool)
+	for e := range entries {
+		if n := len(entries); n > buffer {
+			t.Errorf("%d entries buffered, want at most %d", n, buffer)
+		}
+		rel, _ := filepath.Rel(root, e.Path)
+		walked[filepath.ToSlash(rel)] = true
+		time.Sleep(100 * time.Microsecond)
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("WalkRepoChan() error = %v", err)
+	}
+	if len(walked) != 55 {
+		t.Errorf("received %d entries, want 55", len(walked))
+	}
+	assertWalked(t, walked, []string{"dir0", "dir0/f0.txt", "dir4/f49.txt"}, []string{"skip.log", ".gitignore"})
+}
+
+func TestWalkRepoChanCancel(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{}
+	for i := 0; i < 20; i++ {
+		files[fmt.Sprintf("f%d.txt", i)] = "content"
+	}
+	writeTree(t, root, files)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	entries, errc := WalkRepoChan(ctx, root)
+	<-entries
+	cancel()
+
+	// The walk ends, closing the channel, though entries remain unreceived.
+	received := 1
+	for range entries {
+		received++
+	}
+	if received == 20 {
+		t.Skip("walk finished before it could be cancelled")
+	}
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Errorf("WalkRepoChan() error = %v, want context.Canceled", err)
+	}
+}
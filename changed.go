@@ -0,0 +1,78 @@
+package walkrepo
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+)
+
+// WalkChanged walks only those files beneath root which git status reports as
+// changed: files modified, added or renamed in the index or the working tree,
+// and untracked files. Deleted files, having nothing to walk, are not
+// reported, and nor are directories. Files are passed to walkFn in path order.
+//
+// root may be anywhere within the repository's working tree. As a sanity
+// check, each changed file is also checked against the ignore rules beneath
+// root, configured by opts as for CheckIgnore, so that a tracked file which
+// the rules exclude is skipped as WalkRepo would skip it.
+func WalkChanged(root string, walkFn filepath.WalkFunc, opts ...Option) error {
+	root = filepath.Clean(root)
+	repo, err := git.PlainOpenWithOptions(root, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return fmt.Errorf("walkrepo: opening repository at %s: %w", root, err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+	status, err := wt.Status()
+	if err != nil {
+		return err
+	}
+
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	var changed []string
+	for file, s := range status {
+		if s.Staging == git.Unmodified && s.Worktree == git.Unmodified {
+			continue
+		}
+		rel, err := filepath.Rel(absRoot, filepath.Join(wt.Filesystem.Root(), filepath.FromSlash(file)))
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		changed = append(changed, rel)
+	}
+	sort.Strings(changed)
+
+	checker, err := newIgnoreChecker(root, newConfig(opts))
+	if err != nil {
+		return err
+	}
+	for _, rel := range changed {
+		path := filepath.Join(root, rel)
+		info, err := os.Lstat(path)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		result, err := checker.check(rel)
+		if err != nil {
+			return err
+		}
+		if result.Ignored {
+			continue
+		}
+		if err := walkFn(path, info, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
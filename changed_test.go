@@ -0,0 +1,101 @@
+package walkrepo
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestWalkChanged(t *testing.T) {
+	root := t.TempDir()
+	repo, err := git.PlainInit(root, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTree(t, root, map[string]string{
+		".gitignore":       "*.log\n",
+		"unchanged.txt":    "content",
+		"modified.txt":     "content",
+		"staged.txt":       "content",
+		"deleted.txt":      "content",
+		"sub/modified.txt": "content",
+		"tracked.log":      "content",
+	})
+	for _, path := range []string{".gitignore", "unchanged.txt", "modified.txt", "staged.txt", "deleted.txt", "sub/modified.txt", "tracked.log"} {
+		if _, err := wt.Add(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err = wt.Commit("initial", &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeTree(t, root, map[string]string{
+		"modified.txt":     "unstaged change",
+		"staged.txt":       "staged change",
+		"added.txt":        "content",
+		"sub/modified.txt": "unstaged change",
+		"untracked.txt":    "content",
+		"untracked.log":    "content",
+		"tracked.log":      "changed, but ignored",
+	})
+	for _, path := range []string{"staged.txt", "added.txt"} {
+		if _, err := wt.Add(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Remove(filepath.Join(root, "deleted.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	walk := func(t *testing.T, root string) []string {
+		t.Helper()
+		var walked []string
+		err := WalkChanged(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				t.Errorf("directory %s was walked", path)
+			}
+			rel, _ := filepath.Rel(root, path)
+			walked = append(walked, filepath.ToSlash(rel))
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("WalkChanged() error = %v", err)
+		}
+		return walked
+	}
+
+	want := []string{"added.txt", "modified.txt", "staged.txt", "sub/modified.txt", "untracked.txt"}
+	if got := walk(t, root); !reflect.DeepEqual(got, want) {
+		t.Errorf("WalkChanged() walked %q, want %q", got, want)
+	}
+
+	// From a subdirectory, only the changes beneath it are walked.
+	if got, want := walk(t, filepath.Join(root, "sub")), []string{"modified.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("WalkChanged() from sub walked %q, want %q", got, want)
+	}
+}
+
+func TestWalkChangedNoRepo(t *testing.T) {
+	err := WalkChanged(t.TempDir(), func(path string, info os.FileInfo, err error) error {
+		return err
+	})
+	if err == nil {
+		t.Error("WalkChanged() outside a repository succeeded, want an error")
+	}
+}
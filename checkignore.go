@@ -0,0 +1,284 @@
+package walkrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+)
+
+// CheckResult reports the outcome of CheckIgnore for a single path, in the
+// manner of `git check-ignore -v`.
+type CheckResult struct {
+	// Path is the path as it was given to CheckIgnore.
+	Path string
+	// Ignored reports whether the path is excluded by the ignore rules.
+	Ignored bool
+	// Source, Line and Pattern identify the pattern responsible for the
+	// outcome, and are empty when no pattern matched. As with git, a matching
+	// negation is reported even though it leaves the path un-ignored.
+	Source  string
+	Line    int
+	Pattern string
+}
+
+// CheckIgnore reports, for each of paths, whether it is ignored by the rules
+// in effect beneath root, and which pattern decided it. Paths may be relative
+// to root or absolute, and need not exist; a path is taken to be a directory
+// if it exists as one, or is given with a trailing separator.
+//
+// As in git, a path inside an ignored directory is ignored by the pattern
+// which excluded that directory.
+func CheckIgnore(root string, paths []string, opts ...Option) ([]CheckResult, error) {
+	c, err := newIgnoreChecker(root, newConfig(opts))
+	if err != nil {
+		return nil, err
+	}
+	results := make([]CheckResult, 0, len(paths))
+	for _, path := range paths {
+		result, err := c.check(path)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// WouldIgnore reports, for each of candidatePaths, whether the ignore rules
+// in effect beneath root would exclude it, as for warning about new files
+// before they are added. Paths are taken as CheckIgnore takes them, and need
+// not exist yet; the ignore files along each path are those on disk now. The
+// result is keyed by the paths as given.
+func WouldIgnore(root string, candidatePaths []string, opts ...Option) (map[string]bool, error) {
+	results, err := CheckIgnore(root, candidatePaths, opts...)
+	if err != nil {
+		return nil, err
+	}
+	ignored := make(map[string]bool, len(results))
+	for _, result := range results {
+		ignored[result.Path] = result.Ignored
+	}
+	return ignored, nil
+}
+
+// ignoreChecker resolves ignore decisions for individual paths beneath root,
+// loading the ignore files along each path on demand.
+type ignoreChecker struct {
+	root string
+	cfg  *config
+	base []SourcedPattern
+	dirs map[string][]SourcedPattern // ignore file patterns by directory
+}
+
+func newIgnoreChecker(root string, cfg *config) (*ignoreChecker, error) {
+	root = filepath.Clean(root)
+	base, err := cfg.basePatterns(root)
+	if err != nil {
+		return nil, err
+	}
+	return &ignoreChecker{root: root, cfg: cfg, base: base, dirs: map[string][]SourcedPattern{}}, nil
+}
+
+func (c *ignoreChecker) check(path string) (CheckResult, error) {
+	result := CheckResult{Path: path}
+	components, isDir, err := c.components(path)
+	if err != nil {
+		return result, err
+	}
+
+	patterns := c.base
+	for i := range components {
+		sps, err := c.dirPatterns(components[:i])
+		if err != nil {
+			return result, err
+		}
+		patterns = append(patterns[:len(patterns):len(patterns)], sps...)
+
+		last := i == len(components)-1
+		sp, match := lastMatch(patterns, c.domain(components[:i+1]), isDir || !last, c.cfg.ignoreCase)
+		if match == gitignore.NoMatch || (!last && match == gitignore.Include) {
+			continue
+		}
+		result.Ignored = match == gitignore.Exclude
+		result.Source = sp.Source
+		result.Line = sp.Line
+		result.Pattern = sp.Text
+		if result.Ignored {
+			break
+		}
+	}
+	return result, nil
+}
+
+// components splits path into its components relative to the root, and
+// reports whether it names a directory.
+func (c *ignoreChecker) components(path string) ([]string, bool, error) {
+	rel := path
+	if filepath.IsAbs(path) {
+		absRoot, err := filepath.Abs(c.root)
+		if err != nil {
+			return nil, false, err
+		}
+		if rel, err = filepath.Rel(absRoot, path); err != nil {
+			return nil, false, err
+		}
+	}
+	rel = filepath.Clean(rel)
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, false, fmt.Errorf("path %s is not beneath root %s", path, c.root)
+	}
+
+	isDir := strings.HasSuffix(path, string(filepath.Separator)) || strings.HasSuffix(path, "/")
+	if info, err := os.Lstat(filepath.Join(c.root, rel)); err == nil {
+		isDir = isDir || info.IsDir()
+	}
+	return strings.Split(rel, string(filepath.Separator)), isDir, nil
+}
+
+// domain returns the components of the path, given relative to the root,
+// as the walk matches them: beneath the prefix given to WithDomainPrefix.
+func (c *ignoreChecker) domain(components []string) []string {
+	if len(c.cfg.domainPrefix) == 0 {
+		return components
+	}
+	return append(c.cfg.domainPrefix[:len(c.cfg.domainPrefix):len(c.cfg.domainPrefix)], components...)
+}
+
+// dirPatterns returns the patterns of the ignore files in the directory whose
+// components, relative to the root, are rel. Directories which don't exist
+// contribute no patterns.
+func (c *ignoreChecker) dirPatterns(rel []string) ([]SourcedPattern, error) {
+	key := strings.Join(rel, "/")
+	if sps, ok := c.dirs[key]; ok {
+		return sps, nil
+	}
+
+	dir := filepath.Join(append([]string{c.root}, rel...)...)
+	files, err := c.cfg.listDir(dir)
+	if os.IsNotExist(err) {
+		c.dirs[key] = nil
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	sps, err := c.cfg.ignorePatternsIn(dir, c.domain(rel), files, nil)
+	if err != nil {
+		return nil, err
+	}
+	c.dirs[key] = sps
+	return sps, nil
+}
+
+// lastMatch returns the highest-priority pattern matching path, which is
+// the last one to match in patterns, along with its result. Patterns match
+// without regard to case if foldCase is set.
+func lastMatch(patterns []SourcedPattern, path []string, isDir, foldCase bool) (SourcedPattern, gitignore.MatchResult) {
+	for i := len(patterns) - 1; i >= 0; i-- {
+		if match := matchPattern(patterns[i], path, isDir, foldCase); match != gitignore.NoMatch {
+			return patterns[i], match
+		}
+	}
+	return SourcedPattern{}, gitignore.NoMatch
+}
+
+// errFileFound stops the walk of IsDirFullyIgnored at its first file.
+var errFileFound = errors.New("walkrepo: file found")
+
+// subdir returns the directory dir, given relative to root or as an absolute
+// path, relative to root. It returns "" for root itself.
+func subdir(root, dir string) (string, error) {
+	rel := dir
+	if filepath.IsAbs(dir) {
+		absRoot, err := filepath.Abs(root)
+		if err != nil {
+			return "", err
+		}
+		if rel, err = filepath.Rel(absRoot, dir); err != nil {
+			return "", err
+		}
+	}
+	rel = filepath.Clean(rel)
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path %s is not beneath root %s", dir, root)
+	}
+	if rel == "." {
+		return "", nil
+	}
+	return rel, nil
+}
+
+// IsDirFullyIgnored reports whether every file beneath the directory dir,
+// given relative to root or as an absolute path, is ignored by the rules in
+// effect beneath root, so that a walk of root would report no files there.
+// That is so if dir is itself ignored, or holds no files which aren't.
+func IsDirFullyIgnored(root, dir string, opts ...Option) (bool, error) {
+	sub, err := subdir(root, dir)
+	if err != nil {
+		return false, err
+	}
+
+	err = NewWalker(opts...).walk(root, sub, nil, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return errFileFound
+		}
+		return nil
+	})
+	if err == errFileFound {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// EffectivePatterns returns the text of the ignore patterns in effect for the
+// entries of the directory dir, given relative to root or as an absolute path,
+// in increasing order of precedence: those contributed by options such as
+// WithGlobalExcludes, then those of each ignore file from root's down to
+// dir's own, each in file order. As in git, the last pattern to match an
+// entry decides whether it is ignored, so that a directory's own patterns
+// override those it inherits.
+//
+// If dir would not be walked, being ignored itself or lying within an ignored
+// directory, no patterns are in effect there and nil is returned.
+func EffectivePatterns(root, dir string, opts ...Option) ([]string, error) {
+	sub, err := subdir(root, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &walker{root: filepath.Clean(root), cfg: newConfig(opts), ctx: context.Background()}
+	task, err := w.start()
+	if err != nil {
+		return nil, err
+	}
+	if sub != "" {
+		var walked bool
+		if task, walked, err = w.descend(task, sub); err != nil || !walked {
+			return nil, err
+		}
+	}
+	files, err := w.cfg.listDir(task.path)
+	if err != nil {
+		return nil, err
+	}
+	patterns, _, err := w.localRules(task.path, task.domain, files, task.patterns, task.attrs)
+	if err != nil {
+		return nil, err
+	}
+
+	texts := make([]string, len(patterns))
+	for i, sp := range patterns {
+		texts[i] = sp.Text
+	}
+	return texts, nil
+}
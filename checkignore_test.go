@@ -0,0 +1,198 @@
+package walkrepo
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCheckIgnore(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":     "# comment\n*.log\n\n!important.log\nbuild/\n",
+		"sub/.gitignore": "deep/\n!keep.txt\n",
+		"a.log":          "content",
+		"important.log":  "content",
+		"build/x/f.txt":  "content",
+		"logs/x.log":     "content",
+		"sub/deep/y":     "content",
+		"sub/keep.txt":   "content",
+		"readme.md":      "content",
+	})
+	rootIgnore := filepath.Join(root, ".gitignore")
+	subIgnore := filepath.Join(root, "sub", ".gitignore")
+
+	// Expectations taken from `git check-ignore -v --non-matching` over the
+	// same tree.
+	tests := []CheckResult{
+		{Path: "a.log", Ignored: true, Source: rootIgnore, Line: 2, Pattern: "*.log"},
+		{Path: "important.log", Ignored: false, Source: rootIgnore, Line: 4, Pattern: "!important.log"},
+		{Path: "build/x/f.txt", Ignored: true, Source: rootIgnore, Line: 5, Pattern: "build/"},
+		{Path: "build", Ignored: true, Source: rootIgnore, Line: 5, Pattern: "build/"},
+		{Path: "logs/x.log", Ignored: true, Source: rootIgnore, Line: 2, Pattern: "*.log"},
+		{Path: "sub/deep/y", Ignored: true, Source: subIgnore, Line: 1, Pattern: "deep/"},
+		{Path: "sub/keep.txt", Ignored: false, Source: subIgnore, Line: 2, Pattern: "!keep.txt"},
+		{Path: "readme.md", Ignored: false},
+		{Path: "nothere.log", Ignored: true, Source: rootIgnore, Line: 2, Pattern: "*.log"},
+		{Path: filepath.Join(root, "a.log"), Ignored: true, Source: rootIgnore, Line: 2, Pattern: "*.log"},
+	}
+
+	paths := make([]string, len(tests))
+	for i, tt := range tests {
+		paths[i] = filepath.FromSlash(tt.Path)
+		tests[i].Path = paths[i]
+	}
+	results, err := CheckIgnore(root, paths)
+	if err != nil {
+		t.Fatalf("CheckIgnore() error = %v", err)
+	}
+	if len(results) != len(tests) {
+		t.Fatalf("CheckIgnore() returned %d results, want %d", len(results), len(tests))
+	}
+	for i, want := range tests {
+		if results[i] != want {
+			t.Errorf("CheckIgnore(%q) = %+v, want %+v", want.Path, results[i], want)
+		}
+	}
+}
+
+func TestCheckIgnoreOutsideRoot(t *testing.T) {
+	root := t.TempDir()
+	for _, path := range []string{"..", "../elsewhere.txt", filepath.Dir(root)} {
+		if _, err := CheckIgnore(root, []string{path}); err == nil {
+			t.Errorf("CheckIgnore(%q) error = nil, want an error", path)
+		}
+	}
+}
+
+func TestWouldIgnore(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":     "build/\n*.tmp\n!keep.tmp\ngen/\n",
+		"sub/.gitignore": "*.gen\n",
+		"build/a.o":      "content",
+	})
+
+	// Expectations taken from `git check-ignore --no-index` over the same
+	// tree, in which none of the candidates exist.
+	want := map[string]bool{
+		"build/new.o":      true,
+		"build/keep.tmp":   true,
+		"src/new.go":       false,
+		"sub/new.gen":      true,
+		"sub/deeper/x.gen": true,
+		"new.gen":          false,
+		"a.tmp":            true,
+		"keep.tmp":         false,
+		"gen/out.txt":      true,
+	}
+	var candidates []string
+	native := make(map[string]bool, len(want))
+	for path, ignored := range want {
+		candidates = append(candidates, filepath.FromSlash(path))
+		native[filepath.FromSlash(path)] = ignored
+	}
+	got, err := WouldIgnore(root, candidates)
+	if err != nil {
+		t.Fatalf("WouldIgnore() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, native) {
+		t.Errorf("WouldIgnore() = %v, want %v", got, native)
+	}
+	if _, err := os.Stat(filepath.Join(root, "src")); !os.IsNotExist(err) {
+		t.Errorf("WouldIgnore() touched the tree: %v", err)
+	}
+}
+
+func TestIsDirFullyIgnored(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":              "*.log\nbuild/\n",
+		"logs/a.log":              "content",
+		"logs/old/b.log":          "content",
+		"mixed/a.log":             "content",
+		"mixed/deep/keep.txt":     "content",
+		"build/out.txt":           "content",
+		"nested/.gitignore":       "*\n!.gitignore\n",
+		"nested/x.txt":            "content",
+		"nested/sub/y.txt":        "content",
+		"negated/.gitignore":      "!important.log\n",
+		"negated/important.log":   "content",
+		"negated/other.log":       "content",
+		"inherited/sub/.keep.log": "content",
+	})
+	if err := os.MkdirAll(filepath.Join(root, "empty"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	for dir, want := range map[string]bool{
+		"logs":          true,
+		"logs/old":      true,
+		"mixed":         false,
+		"mixed/deep":    false,
+		"build":         true,
+		"nested":        true,
+		"nested/sub":    true,
+		"negated":       false,
+		"inherited/sub": true,
+		"empty":         true,
+	} {
+		got, err := IsDirFullyIgnored(root, filepath.FromSlash(dir))
+		if err != nil {
+			t.Errorf("IsDirFullyIgnored(%q) error = %v", dir, err)
+		} else if got != want {
+			t.Errorf("IsDirFullyIgnored(%q) = %v, want %v", dir, got, want)
+		}
+	}
+
+	// Absolute paths are accepted too.
+	if got, err := IsDirFullyIgnored(root, filepath.Join(root, "mixed")); err != nil || got {
+		t.Errorf("IsDirFullyIgnored(absolute mixed) = %v, %v, want false", got, err)
+	}
+
+	for _, dir := range []string{"missing", "mixed/a.log", ".."} {
+		if _, err := IsDirFullyIgnored(root, dir); err == nil {
+			t.Errorf("IsDirFullyIgnored(%q) succeeded, want an error", dir)
+		}
+	}
+}
+
+func TestEffectivePatterns(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":        "*.log\n# comment\n!root.log\n",
+		"a/.gitignore":      "!keep.log\n*.tmp\n",
+		"a/b/.gitignore":    "keep.log\n!x.tmp\n",
+		"a/b/keep.log":      "content",
+		"a/b/x.tmp":         "content",
+		"a/keep.log":        "content",
+		"a/y.tmp":           "content",
+		"ignored.log/a.txt": "content",
+	})
+	t.Setenv("WALKREPO_TEST_PATTERNS", "env")
+
+	tests := []struct {
+		dir  string
+		want []string
+	}{
+		{"", []string{"env", "*.log", "!root.log"}},
+		{"a", []string{"env", "*.log", "!root.log", "!keep.log", "*.tmp"}},
+		{filepath.Join("a", "b"), []string{"env", "*.log", "!root.log", "!keep.log", "*.tmp", "keep.log", "!x.tmp"}},
+		{filepath.Join(root, "a", "b"), []string{"env", "*.log", "!root.log", "!keep.log", "*.tmp", "keep.log", "!x.tmp"}},
+		{"ignored.log", nil},
+	}
+	for _, tt := range tests {
+		got, err := EffectivePatterns(root, tt.dir, WithEnvPatterns("WALKREPO_TEST_PATTERNS"))
+		if err != nil {
+			t.Errorf("EffectivePatterns(%q) error = %v", tt.dir, err)
+		} else if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("EffectivePatterns(%q) = %q, want %q", tt.dir, got, tt.want)
+		}
+	}
+
+	// Later patterns take precedence, so each directory's own patterns
+	// override those it inherits, in either direction.
+	walked := walkedPaths(t, root)
+	assertWalked(t, walked, []string{"a/keep.log", "a/b/x.tmp"}, []string{"a/b/keep.log", "a/y.tmp"})
+}
@@ -0,0 +1,17 @@
+package walkrepo
+
+import "io/fs"
+
+// QuickCount counts the directories and files a walk of root would report,
+// for sizing progress bars and the like. It walks as WalkRepoDir does, so no
+// entry is stat'ed, and is much cheaper than counting with WalkRepo. The root
+// itself is not counted.
+func QuickCount(root string, opts ...Option) (dirs, files int, err error) {
+	wk := NewWalker(opts...)
+	err = wk.WalkDir(root, func(string, fs.DirEntry, error) error { return nil })
+	if err != nil {
+		return 0, 0, err
+	}
+	stats := wk.Stats()
+	return stats.Dirs, stats.Files, nil
+}
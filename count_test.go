@@ -0,0 +1,64 @@
+package walkrepo
+
+import (
+	"os"
+	"testing"
+)
+
+func TestQuickCount(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":       "*.log\nbuild/\n",
+		"a.txt":            "content",
+		"a.log":            "content",
+		"build/out.bin":    "content",
+		"src/.gitignore":   "!keep.log\n",
+		"src/lib.go":       "content",
+		"src/keep.log":     "content",
+		"src/deep/x.go":    "content",
+		"src/deep/x.log":   "content",
+		"src/empty/.keep":  "",
+		"docs/readme.md":   "content",
+		"docs/old/old.log": "content",
+	})
+
+	var wantDirs, wantFiles int
+	err := WalkRepo(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			wantDirs++
+		} else {
+			wantFiles++
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkRepo() error = %v", err)
+	}
+
+	dirs, files, err := QuickCount(root)
+	if err != nil {
+		t.Fatalf("QuickCount() error = %v", err)
+	}
+	if dirs != wantDirs || files != wantFiles {
+		t.Errorf("QuickCount() = %d dirs, %d files, want %d dirs, %d files", dirs, files, wantDirs, wantFiles)
+	}
+	if dirs != 5 || files != 6 {
+		t.Errorf("QuickCount() = %d dirs, %d files, want 5 dirs, 6 files", dirs, files)
+	}
+}
+
+func BenchmarkQuickCount(b *testing.B) {
+	root := b.TempDir()
+	buildBenchTree(b, root, 4, 6, 8, 50)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := QuickCount(root); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
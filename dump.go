@@ -0,0 +1,66 @@
+package walkrepo
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DumpIgnoreStack writes to out a listing of every source of ignore patterns
+// a walk of root would apply, in increasing order of precedence for any one
+// directory: first those contributed by options, such as WithGlobalExcludes,
+// then the ignore files of each walked directory, in path order. Each source
+// is named (relative to root, for files beneath it) and followed by its
+// patterns, indented, each with its line number. Ignore files in ignored
+// directories are never read by a walk, and so are not listed.
+func DumpIgnoreStack(root string, out io.Writer, opts ...Option) error {
+	root = filepath.Clean(filepath.FromSlash(root))
+	w := &walker{
+		root:      root,
+		cfg:       newConfig(opts),
+		walkDirFn: func(string, fs.DirEntry, error) error { return nil },
+		ctx:       context.Background(),
+		snapshot:  make(map[string][]SourcedPattern),
+	}
+	start, err := w.start()
+	if err != nil {
+		return err
+	}
+	w.realDirs = start.realDirs
+	if err := w.walk(start.path, start.domain, start.patterns, start.attrs); err != nil {
+		return err
+	}
+
+	dirs := make([]string, 0, len(w.snapshot))
+	for dir := range w.snapshot {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	stack := start.patterns
+	for _, dir := range dirs {
+		stack = append(stack, w.snapshot[dir]...)
+	}
+
+	source := ""
+	for _, sp := range stack {
+		if sp.Source != source {
+			source = sp.Source
+			name := source
+			rel, err := filepath.Rel(root, source)
+			if err == nil && filepath.IsAbs(source) == filepath.IsAbs(root) && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				name = filepath.ToSlash(rel)
+			}
+			if _, err := fmt.Fprintln(out, name); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(out, "\t%d: %s\n", sp.Line, sp.Text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
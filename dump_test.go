@@ -0,0 +1,42 @@
+package walkrepo
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDumpIgnoreStack(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":            "# build output\n*.log\nbuild/\n",
+		"build/.gitignore":      "never-read\n",
+		"src/.gitignore":        "!keep.log\n",
+		"src/lib/.gitignore":    "\n*.tmp\n/gen/\n",
+		"src/lib/a.go":          "content",
+		"docs/.gitignore":       "# nothing but comments\n",
+		"docs/guide/.gitignore": "draft-*\n",
+	})
+	t.Setenv("WALKREPO_TEST_PATTERNS", "*.bak\n*.orig")
+
+	var out bytes.Buffer
+	if err := DumpIgnoreStack(root, &out, WithEnvPatterns("WALKREPO_TEST_PATTERNS")); err != nil {
+		t.Fatalf("DumpIgnoreStack() error = %v", err)
+	}
+	want := `$WALKREPO_TEST_PATTERNS
+	1: *.bak
+	2: *.orig
+.gitignore
+	2: *.log
+	3: build/
+docs/guide/.gitignore
+	1: draft-*
+src/.gitignore
+	1: !keep.log
+src/lib/.gitignore
+	2: *.tmp
+	3: /gen/
+`
+	if got := out.String(); got != want {
+		t.Errorf("DumpIgnoreStack() wrote:\n%s\nwant:\n%s", got, want)
+	}
+}
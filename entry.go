@@ -0,0 +1,55 @@
+package walkrepo
+
+import (
+	"os"
+)
+
+// Entry describes a single file or directory visited by WalkRepoEntries.
+type Entry struct {
+	Path string
+	Info os.FileInfo
+
+	// RealPath is the absolute path of the entry with every symlink in it
+	// resolved, set only with WithResolvePaths. Entries reached by different
+	// paths through symlinks share a RealPath. It is empty if the path can't
+	// be resolved, as for a dangling symlink.
+	RealPath string
+}
+
+// newEntry returns the Entry for the entry at path, described by info,
+// resolving its real path if cfg asks for it.
+func newEntry(cfg *config, path string, info os.FileInfo) Entry {
+	e := Entry{Path: path, Info: info}
+	if cfg.resolvePaths {
+		e.RealPath, _ = realPath(path)
+	}
+	return e
+}
+
+// IsSymlink reports whether the entry is a symbolic link.
+func (e Entry) IsSymlink() bool {
+	return e.Info.Mode()&os.ModeSymlink != 0
+}
+
+// LinkTarget returns the target of a symlink entry, as reported by
+// os.Readlink. The target is only read when asked for, so walks which don't
+// need it pay no extra syscalls. For entries which are not symlinks it
+// returns "" and a nil error.
+func (e Entry) LinkTarget() (string, error) {
+	if !e.IsSymlink() {
+		return "", nil
+	}
+	return os.Readlink(e.Path)
+}
+
+// WalkRepoEntries is WalkRepo with a callback which receives each entry as an
+// Entry. Returning filepath.SkipDir from fn for a directory skips it.
+func WalkRepoEntries(root string, fn func(Entry) error, opts ...Option) error {
+	cfg := newConfig(opts)
+	return WalkRepo(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		return fn(newEntry(cfg, path, info))
+	}, opts...)
+}
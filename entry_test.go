@@ -0,0 +1,92 @@
+package walkrepo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWalkRepoEntriesLinkTarget(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"target.txt": "content",
+		"plain.txt":  "content",
+	})
+	if err := os.Symlink("target.txt", filepath.Join(root, "link")); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+
+	targets := make(map[string]string)
+	err := WalkRepoEntries(root, func(e Entry) error {
+		target, err := e.LinkTarget()
+		if err != nil {
+			return err
+		}
+		if e.IsSymlink() != (e.Info.Name() == "link") {
+			t.Errorf("IsSymlink() = %v for %q", e.IsSymlink(), e.Path)
+		}
+		targets[e.Info.Name()] = target
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkRepoEntries() error = %v", err)
+	}
+
+	if targets["link"] != "target.txt" {
+		t.Errorf("LinkTarget() for link = %q, want %q", targets["link"], "target.txt")
+	}
+	for _, name := range []string{"target.txt", "plain.txt"} {
+		if target, ok := targets[name]; !ok || target != "" {
+			t.Errorf("LinkTarget() for %s = %q, %v; want empty", name, target, ok)
+		}
+	}
+}
+
+func TestWithResolvePaths(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"dir/target.txt": "content",
+		"plain.txt":      "content",
+	})
+	if err := os.Symlink(filepath.Join("dir", "target.txt"), filepath.Join(root, "link")); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+	if err := os.Symlink("missing", filepath.Join(root, "dangling")); err != nil {
+		t.Fatal(err)
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	collect := func(opts ...Option) map[string]string {
+		real := make(map[string]string)
+		err := WalkRepoEntries(root, func(e Entry) error {
+			rel, _ := filepath.Rel(root, e.Path)
+			real[filepath.ToSlash(rel)] = e.RealPath
+			return nil
+		}, opts...)
+		if err != nil {
+			t.Fatalf("WalkRepoEntries() error = %v", err)
+		}
+		return real
+	}
+
+	for path, real := range collect() {
+		if real != "" {
+			t.Errorf("without WithResolvePaths, %s has RealPath %q", path, real)
+		}
+	}
+
+	real := collect(WithResolvePaths(true))
+	target := filepath.Join(realRoot, "dir", "target.txt")
+	if real["link"] != target || real["dir/target.txt"] != target {
+		t.Errorf("RealPath of link = %q and of its target = %q, want both %q", real["link"], real["dir/target.txt"], target)
+	}
+	if want := filepath.Join(realRoot, "plain.txt"); real["plain.txt"] != want {
+		t.Errorf("RealPath of plain.txt = %q, want %q", real["plain.txt"], want)
+	}
+	if real["dangling"] != "" {
+		t.Errorf("RealPath of dangling = %q, want empty", real["dangling"])
+	}
+}
@@ -0,0 +1,41 @@
+package walkrepo
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ExtStat totals the files of one extension, as counted by ExtensionStats.
+type ExtStat struct {
+	Count int
+	Bytes int64
+}
+
+// ExtensionStats walks root, totalling its non-ignored files by extension.
+// Extensions are lowercased and include their dot, as in ".go". Files with
+// no extension, including dotfiles such as ".env", are totalled under "".
+func ExtensionStats(root string, opts ...Option) (map[string]ExtStat, error) {
+	stats := make(map[string]ExtStat)
+	err := WalkRepo(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		ext := strings.ToLower(filepath.Ext(info.Name()))
+		if ext == strings.ToLower(info.Name()) {
+			ext = ""
+		}
+		stat := stats[ext]
+		stat.Count++
+		stat.Bytes += info.Size()
+		stats[ext] = stat
+		return nil
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
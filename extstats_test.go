@@ -0,0 +1,37 @@
+package walkrepo
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtensionStats(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":       "*.log\nvendor/\n",
+		"main.go":          "12345",
+		"src/util.go":      "123",
+		"src/UTIL_TEST.GO": "1",
+		"README.md":        "1234567890",
+		"docs/guide.Md":    "12",
+		"Makefile":         "1234",
+		".env":             "12",
+		"archive.tar.gz":   "123",
+		"debug.log":        "ignored",
+		"vendor/x.go":      "ignored",
+	})
+
+	stats, err := ExtensionStats(root)
+	if err != nil {
+		t.Fatalf("ExtensionStats() error = %v", err)
+	}
+	want := map[string]ExtStat{
+		".go": {Count: 3, Bytes: 9},
+		".md": {Count: 2, Bytes: 12},
+		".gz": {Count: 1, Bytes: 3},
+		"":    {Count: 2, Bytes: 6},
+	}
+	if !reflect.DeepEqual(stats, want) {
+		t.Errorf("ExtensionStats() = %v, want %v", stats, want)
+	}
+}
@@ -0,0 +1,146 @@
+package walkrepo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// TreeHash returns a hex-encoded SHA-256 digest of the non-ignored files
+// beneath root, suitable for cheaply detecting whether any of them changed.
+// The files are hashed in sorted order by their slash-separated relative
+// paths, together with their sizes and modification times or, with
+// WithHashContents, their contents. Symlinks contribute their targets rather
+// than the contents they point to.
+//
+// With WithMaxTotalBytes, contents are read only until the cap is reached,
+// and the files beyond it are left out of the digest. The file being read
+// when the cap is reached is left out too, unless WithTruncateAtCap includes
+// as much of it as was read.
+func TreeHash(root string, opts ...Option) (string, error) {
+	root, opts, err := rootRelative(root, opts)
+	if err != nil {
+		return "", err
+	}
+	cfg := newConfig(opts)
+
+	type file struct {
+		rel  string
+		path string
+		info os.FileInfo
+	}
+	var files []file
+	err = WalkRepo(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, file{filepath.ToSlash(rel), path, info})
+		return nil
+	}, opts...)
+	if err != nil {
+		return "", err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].rel < files[j].rel })
+
+	h := sha256.New()
+	budget := newHashBudget(cfg)
+hashing:
+	for _, f := range files {
+		switch {
+		case f.info.Mode()&os.ModeSymlink != 0:
+			target, err := os.Readlink(f.path)
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(h, "%s\x00link\x00%s\x00", f.rel, target)
+		case cfg.hashContents:
+			sum, ok, err := budget.hash(f.path)
+			if err != nil {
+				return "", err
+			}
+			if !ok {
+				break hashing
+			}
+			fmt.Fprintf(h, "%s\x00", f.rel)
+			h.Write(sum)
+		default:
+			fmt.Fprintf(h, "%s\x00%d\x00%d\x00", f.rel, f.info.Size(), f.info.ModTime().UnixNano())
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashBudget caps the content read in hashing a sequence of files at the
+// total set by WithMaxTotalBytes, if any.
+type hashBudget struct {
+	remaining int64 // of content yet to be read, if capped
+	capped    bool
+	truncate  bool
+}
+
+func newHashBudget(cfg *config) *hashBudget {
+	return &hashBudget{remaining: cfg.maxTotalBytes, capped: cfg.maxTotalBytes > 0, truncate: cfg.truncateAtCap}
+}
+
+// hash returns the SHA-256 digest of the file at path, reading no more of it
+// than remains of the budget. ok is false once the budget is spent, or if
+// the file was cut short at the cap without WithTruncateAtCap, in which case
+// neither it nor any later file is to be included.
+func (b *hashBudget) hash(path string) (sum []byte, ok bool, err error) {
+	limit := int64(-1)
+	if b.capped {
+		if b.remaining <= 0 {
+			return nil, false, nil
+		}
+		limit = b.remaining
+	}
+	sum, n, truncated, err := hashFile(path, limit)
+	if err != nil {
+		return nil, false, err
+	}
+	b.remaining -= n
+	if truncated && !b.truncate {
+		return nil, false, nil
+	}
+	return sum, true, nil
+}
+
+// hashFile returns the SHA-256 digest of the contents of the file at path,
+// streaming the file rather than reading it whole. If limit is not negative,
+// no more than limit bytes are read, and truncated reports whether the file
+// had more. n is the number of bytes read.
+func hashFile(path string, limit int64) (sum []byte, n int64, truncated bool, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	defer f.Close()
+
+	fh := sha256.New()
+	if limit < 0 {
+		n, err = io.Copy(fh, f)
+		return fh.Sum(nil), n, false, err
+	}
+	if n, err = io.CopyN(fh, f, limit); err != nil && err != io.EOF {
+		return nil, n, false, err
+	}
+	if n == limit {
+		more, err := f.Read(make([]byte, 1))
+		if err != nil && err != io.EOF {
+			return nil, n, false, err
+		}
+		truncated = more > 0
+	}
+	return fh.Sum(nil), n, truncated, nil
+}
@@ -0,0 +1,118 @@
+package walkrepo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTreeHash(t *testing.T) {
+	for _, contents := range []bool{false, true} {
+		name := map[bool]string{false: "size and mtime", true: "contents"}[contents]
+		t.Run(name, func(t *testing.T) {
+			root := t.TempDir()
+			writeTree(t, root, map[string]string{
+				".gitignore":  "*.log",
+				"a.txt":       "alpha",
+				"sub/b.txt":   "bravo",
+				"sub/c.log":   "charlie",
+				"sub/d/e.txt": "echo",
+			})
+			opts := []Option{WithHashContents(contents)}
+
+			hash := func() string {
+				t.Helper()
+				h, err := TreeHash(root, opts...)
+				if err != nil {
+					t.Fatalf("TreeHash() error = %v", err)
+				}
+				return h
+			}
+
+			first := hash()
+			if second := hash(); second != first {
+				t.Errorf("TreeHash() is not stable: %s then %s", first, second)
+			}
+
+			// Changes to ignored files don't affect the hash.
+			writeTree(t, root, map[string]string{"sub/c.log": "changed"})
+			if h := hash(); h != first {
+				t.Errorf("TreeHash() changed after modifying an ignored file")
+			}
+
+			// Changes to walked files do.
+			writeTree(t, root, map[string]string{"sub/b.txt": "BRAVO"})
+			later := time.Now().Add(time.Hour)
+			if err := os.Chtimes(filepath.Join(root, "sub", "b.txt"), later, later); err != nil {
+				t.Fatal(err)
+			}
+			if h := hash(); h == first {
+				t.Errorf("TreeHash() did not change after modifying a file")
+			}
+		})
+	}
+}
+
+func TestTreeHashContentsIgnoresMtime(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{"a.txt": "alpha"})
+
+	before, err := TreeHash(root, WithHashContents(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(root, "a.txt"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	after, err := TreeHash(root, WithHashContents(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before != after {
+		t.Errorf("content TreeHash() changed after touching a file")
+	}
+}
+
+func TestTreeHashMaxTotalBytes(t *testing.T) {
+	hash := func(files map[string]string, opts ...Option) string {
+		t.Helper()
+		root := t.TempDir()
+		writeTree(t, root, files)
+		h, err := TreeHash(root, append(opts, WithHashContents(true))...)
+		if err != nil {
+			t.Fatalf("TreeHash() error = %v", err)
+		}
+		return h
+	}
+	files := map[string]string{
+		"a.txt":     "alpha",
+		"b.txt":     "bravo",
+		"sub/c.txt": "charlie",
+	}
+
+	// Files are read in path order until 8 bytes have been read, partway
+	// through b.txt.
+	capped := hash(files, WithMaxTotalBytes(8))
+	if want := hash(map[string]string{"a.txt": "alpha"}); capped != want {
+		t.Errorf("capped TreeHash() = %s, want that of a.txt alone, %s", capped, want)
+	}
+	truncated := hash(files, WithMaxTotalBytes(8), WithTruncateAtCap(true))
+	if want := hash(map[string]string{"a.txt": "alpha", "b.txt": "bra"}); truncated != want {
+		t.Errorf("truncated TreeHash() = %s, want that of a.txt and the start of b.txt, %s", truncated, want)
+	}
+
+	// A cap reached at the end of a file includes it whole, either way.
+	want := hash(map[string]string{"a.txt": "alpha", "b.txt": "bravo"})
+	for _, truncate := range []bool{false, true} {
+		if h := hash(files, WithMaxTotalBytes(10), WithTruncateAtCap(truncate)); h != want {
+			t.Errorf("TreeHash() capped at a file's end, truncating %v = %s, want %s", truncate, h, want)
+		}
+	}
+
+	// A cap beyond the total changes nothing.
+	if h, want := hash(files, WithMaxTotalBytes(1000)), hash(files); h != want {
+		t.Errorf("TreeHash() with a generous cap = %s, want %s", h, want)
+	}
+}
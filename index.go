@@ -0,0 +1,99 @@
+package walkrepo
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+)
+
+// trackedPaths holds the files git's index tracks beneath a walk's root, for
+// WithRespectIndex. Paths are relative to the root, with forward slashes.
+type trackedPaths struct {
+	files map[string]bool // tracked files
+	dirs  map[string]bool // directories containing tracked files, at any depth
+
+	// within holds the ignored directories walked for the tracked files
+	// they contain, whose untracked entries stay ignored.
+	within map[string]bool
+}
+
+// loadTracked reads the index of the repository enclosing root, as found by
+// FindRepoRoot. Outside a repository, or in one with no index yet, nothing
+// is tracked.
+func loadTracked(root string) (*trackedPaths, error) {
+	t := &trackedPaths{files: map[string]bool{}, dirs: map[string]bool{}, within: map[string]bool{}}
+	repoRoot, err := FindRepoRoot(root)
+	if errors.Is(err, ErrNoRepo) {
+		return t, nil
+	} else if err != nil {
+		return nil, err
+	}
+	dir, err := gitDir(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filepath.Join(dir, "index"))
+	if os.IsNotExist(err) {
+		return t, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	idx := &index.Index{}
+	if err := index.NewDecoder(bufio.NewReader(f)).Decode(idx); err != nil {
+		return nil, err
+	}
+
+	// Index entries are relative to the repository's root, which may lie
+	// above the walk's.
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	prefix, err := filepath.Rel(repoRoot, absRoot)
+	if err != nil {
+		return nil, err
+	}
+	prefix = filepath.ToSlash(prefix) + "/"
+	if prefix == "./" {
+		prefix = ""
+	}
+	for _, e := range idx.Entries {
+		if !strings.HasPrefix(e.Name, prefix) {
+			continue
+		}
+		name := e.Name[len(prefix):]
+		t.files[name] = true
+		for i := strings.LastIndexByte(name, '/'); i >= 0; i = strings.LastIndexByte(name[:i], '/') {
+			t.dirs[name[:i]] = true
+		}
+	}
+	return t, nil
+}
+
+// ignored decides whether the entry called name within the directory dir
+// (relative to the root, with forward slashes) is ignored, given whether the
+// ignore rules would ignore it. As in git, tracked files are never ignored,
+// and nor are the directories containing them; the untracked entries within
+// such a directory stay ignored.
+func (t *trackedPaths) ignored(dir, name string, isDir, ignored bool) bool {
+	rel := name
+	if dir != "" {
+		rel = dir + "/" + name
+	}
+	if t.within[dir] {
+		ignored = true
+	}
+	if !ignored {
+		return false
+	}
+	if isDir && t.dirs[rel] {
+		t.within[rel] = true
+		return false
+	}
+	return !t.files[rel]
+}
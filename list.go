@@ -0,0 +1,97 @@
+package walkrepo
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ListFilesPartial returns the paths, relative to root, of the non-ignored
+// files (but not directories) beneath it. If the walk fails partway, the
+// files found before the failure are returned along with the error, so that
+// callers can still make use of them.
+//
+// Paths are relative to the root the walk starts from, which is the
+// repository root with WithDiscoverRoot; options which change the form of
+// reported paths, such as WithRelativeTo and WithAbsolutePaths, have no
+// effect here or in the other helpers which return relative paths.
+func ListFilesPartial(root string, opts ...Option) ([]string, error) {
+	root, opts, err := rootRelative(root, opts)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	err = WalkRepo(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, rel)
+		return nil
+	}, opts...)
+	return files, err
+}
+
+// FilesByTopDir returns the paths, relative to root, of the non-ignored files
+// beneath it, grouped by the top-level directory they lie within. Files
+// directly within root are grouped under "". Each group is sorted.
+func FilesByTopDir(root string, opts ...Option) (map[string][]string, error) {
+	files, err := ListFilesPartial(root, opts...)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	groups := make(map[string][]string)
+	for _, file := range files {
+		top := ""
+		if i := strings.IndexRune(file, filepath.Separator); i >= 0 {
+			top = file[:i]
+		}
+		groups[top] = append(groups[top], file)
+	}
+	return groups, nil
+}
+
+// CollectFiles returns the paths, relative to root, of up to maxEntries of
+// the non-ignored files (but not directories) beneath it, in walk order, for
+// paging through large trees. It reports whether there were more files than
+// that; if so, the walk stopped as soon as it found one more, without
+// visiting the rest of the tree.
+func CollectFiles(root string, maxEntries int, opts ...Option) ([]string, bool, error) {
+	root, opts, err := rootRelative(root, opts)
+	if err != nil {
+		return nil, false, err
+	}
+	var files []string
+	truncated := false
+	err = WalkRepo(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		if len(files) >= maxEntries {
+			truncated = true
+			return filepath.SkipAll
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, rel)
+		return nil
+	}, opts...)
+	if err != nil {
+		return nil, false, err
+	}
+	return files, truncated, nil
+}
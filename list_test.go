@@ -0,0 +1,135 @@
+package walkrepo
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// injectReadDirError makes readDir fail with err for the directory at
+// failPath, and list every other directory in sorted order, for the duration
+// of the test.
+func injectReadDirError(t *testing.T, failPath string, err error) {
+	t.Helper()
+	orig := readDir
+	readDir = func(path string) ([]fs.DirEntry, error) {
+		if path == failPath {
+			return nil, err
+		}
+		files, err := orig(path)
+		sort.Slice(files, func(i, j int) bool { return files[i].Name() < files[j].Name() })
+		return files, err
+	}
+	t.Cleanup(func() { readDir = orig })
+}
+
+func TestListFilesPartial(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":  "*.log",
+		"a/one.txt":   "content",
+		"a/debug.log": "content",
+		"b/two.txt":   "content",
+		"c/three.txt": "content",
+		"d.txt":       "content",
+	})
+
+	files, err := ListFilesPartial(root)
+	if err != nil {
+		t.Fatalf("ListFilesPartial() error = %v", err)
+	}
+	sort.Strings(files)
+	want := []string{filepath.Join("a", "one.txt"), filepath.Join("b", "two.txt"), filepath.Join("c", "three.txt"), "d.txt"}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("ListFilesPartial() = %v, want %v", files, want)
+	}
+
+	boom := errors.New("boom")
+	injectReadDirError(t, filepath.Join(root, "c"), boom)
+	files, err = ListFilesPartial(root)
+	if err != boom {
+		t.Errorf("ListFilesPartial() error = %v, want %v", err, boom)
+	}
+	want = []string{filepath.Join("a", "one.txt"), filepath.Join("b", "two.txt")}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("ListFilesPartial() = %v, want partial results %v", files, want)
+	}
+}
+
+func TestFilesByTopDir(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":       "*.log\nbuild/\n",
+		"README.md":        "content",
+		"go.mod":           "content",
+		"debug.log":        "content",
+		"cmd/tool/main.go": "content",
+		"cmd/other.go":     "content",
+		"pkg/a/a.go":       "content",
+		"pkg/a/a.log":      "content",
+		"docs/guide.md":    "content",
+		"build/out":        "content",
+		"empty/.gitignore": "*\n",
+	})
+
+	groups, err := FilesByTopDir(root)
+	if err != nil {
+		t.Fatalf("FilesByTopDir() error = %v", err)
+	}
+	want := map[string][]string{
+		"":     {"README.md", "go.mod"},
+		"cmd":  {filepath.Join("cmd", "other.go"), filepath.Join("cmd", "tool", "main.go")},
+		"pkg":  {filepath.Join("pkg", "a", "a.go")},
+		"docs": {filepath.Join("docs", "guide.md")},
+	}
+	if !reflect.DeepEqual(groups, want) {
+		t.Errorf("FilesByTopDir() = %q, want %q", groups, want)
+	}
+}
+
+func TestCollectFiles(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore": "*.log",
+		"a/1.txt":    "content",
+		"a/2.txt":    "content",
+		"a/3.txt":    "content",
+		"a/x.log":    "content",
+		"b/4.txt":    "content",
+		"z/5.txt":    "content",
+	})
+
+	for _, tt := range []struct {
+		max       int
+		wantCount int
+		truncated bool
+	}{
+		{0, 0, true},
+		{4, 4, true},
+		{5, 5, false},
+		{100, 5, false},
+	} {
+		files, truncated, err := CollectFiles(root, tt.max)
+		if err != nil {
+			t.Fatalf("CollectFiles(%d) error = %v", tt.max, err)
+		}
+		if len(files) != tt.wantCount || truncated != tt.truncated {
+			t.Errorf("CollectFiles(%d) = %v, %v; want %d files, %v", tt.max, files, truncated, tt.wantCount, tt.truncated)
+		}
+	}
+
+	// The walk stops at the first file past the limit, here b/4.txt, never
+	// reaching z.
+	injectReadDirError(t, filepath.Join(root, "z"), errors.New("z was read"))
+	files, truncated, err := CollectFiles(root, 3)
+	if err != nil {
+		t.Fatalf("CollectFiles() error = %v", err)
+	}
+	want := []string{filepath.Join("a", "1.txt"), filepath.Join("a", "2.txt"), filepath.Join("a", "3.txt")}
+	if !reflect.DeepEqual(files, want) || !truncated {
+		t.Errorf("CollectFiles() = %v, %v; want %v, true", files, truncated, want)
+	}
+}
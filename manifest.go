@@ -0,0 +1,114 @@
+package walkrepo
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// FileMeta is the size and modification time of a file, as recorded in the
+// manifests of WalkChangedSince.
+type FileMeta struct {
+	Size    int64
+	ModTime time.Time
+}
+
+// WalkChangedSince walks root as WalkRepo does, calling fn only for those
+// non-ignored files (not directories) which are absent from manifest or whose
+// size or modification time differ from those recorded there. It returns a
+// new manifest of every non-ignored file, for use in the next call; files
+// removed since manifest was taken are simply left out of it.
+//
+// Manifests are keyed by slash-separated paths relative to root (the
+// repository root, with WithDiscoverRoot), and fn is passed paths joined onto
+// it, whatever WithRelativeTo or WithAbsolutePaths are set to. A nil manifest
+// treats every file as new.
+func WalkChangedSince(root string, manifest map[string]FileMeta, fn func(path string, info os.FileInfo) error, opts ...Option) (map[string]FileMeta, error) {
+	root, opts, err := rootRelative(root, opts)
+	if err != nil {
+		return nil, err
+	}
+	current := make(map[string]FileMeta)
+	err = WalkRepo(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		meta := FileMeta{Size: info.Size(), ModTime: info.ModTime()}
+		current[key] = meta
+
+		if old, ok := manifest[key]; ok && old.Size == meta.Size && old.ModTime.Equal(meta.ModTime) {
+			return nil
+		}
+		return fn(path, info)
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return current, nil
+}
+
+// Manifest returns the hex-encoded SHA-256 digest of the contents of every
+// non-ignored regular file beneath root, keyed by its slash-separated path
+// relative to root, as for syncing or verifying trees. Files are streamed
+// through the hash rather than read whole. Symlinks and other irregular files
+// are left out.
+//
+// With WithMaxTotalBytes, files are read in path order only until the cap is
+// reached, and those beyond it are left out, as in TreeHash. The file being
+// read when the cap is reached is left out too, unless WithTruncateAtCap
+// includes the digest of as much of it as was read.
+func Manifest(root string, opts ...Option) (map[string]string, error) {
+	root, opts, err := rootRelative(root, opts)
+	if err != nil {
+		return nil, err
+	}
+	cfg := newConfig(opts)
+
+	paths := make(map[string]string) // by slash-separated relative path
+	err = WalkRepo(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		paths[filepath.ToSlash(rel)] = path
+		return nil
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	rels := make([]string, 0, len(paths))
+	for rel := range paths {
+		rels = append(rels, rel)
+	}
+	sort.Strings(rels)
+
+	manifest := make(map[string]string, len(rels))
+	budget := newHashBudget(cfg)
+	for _, rel := range rels {
+		sum, ok, err := budget.hash(paths[rel])
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		manifest[rel] = hex.EncodeToString(sum)
+	}
+	return manifest, nil
+}
@@ -0,0 +1,157 @@
+package walkrepo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestWalkChangedSince(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":     "*.log\n",
+		"same.txt":       "content",
+		"resized.txt":    "content",
+		"touched.txt":    "content",
+		"removed.txt":    "content",
+		"sub/same.txt":   "content",
+		"sub/debug.log":  "content",
+		"sub/nested/a.b": "content",
+	})
+
+	walk := func(t *testing.T, manifest map[string]FileMeta) ([]string, map[string]FileMeta) {
+		t.Helper()
+		var fired []string
+		next, err := WalkChangedSince(root, manifest, func(path string, info os.FileInfo) error {
+			if info.IsDir() {
+				t.Errorf("directory %s fired", path)
+			}
+			rel, _ := filepath.Rel(root, path)
+			fired = append(fired, filepath.ToSlash(rel))
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("WalkChangedSince() error = %v", err)
+		}
+		sort.Strings(fired)
+		return fired, next
+	}
+
+	// Without a manifest, every file is new.
+	fired, manifest := walk(t, nil)
+	all := []string{"removed.txt", "resized.txt", "same.txt", "sub/nested/a.b", "sub/same.txt", "touched.txt"}
+	if !reflect.DeepEqual(fired, all) {
+		t.Errorf("first walk fired %q, want %q", fired, all)
+	}
+	if len(manifest) != len(all) {
+		t.Errorf("manifest has %d entries, want %d", len(manifest), len(all))
+	}
+
+	// Nothing fires for an unchanged tree.
+	if fired, _ := walk(t, manifest); len(fired) != 0 {
+		t.Errorf("unchanged tree fired %q", fired)
+	}
+
+	writeTree(t, root, map[string]string{
+		"resized.txt":   "longer content",
+		"added.txt":     "content",
+		"sub/added.log": "content",
+	})
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(root, "touched.txt"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(root, "removed.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	fired, next := walk(t, manifest)
+	if want := []string{"added.txt", "resized.txt", "touched.txt"}; !reflect.DeepEqual(fired, want) {
+		t.Errorf("changed tree fired %q, want %q", fired, want)
+	}
+	if _, ok := next["removed.txt"]; ok {
+		t.Errorf("new manifest still lists removed.txt")
+	}
+	if next["touched.txt"].ModTime.Equal(manifest["touched.txt"].ModTime) {
+		t.Errorf("new manifest kept the old modification time of touched.txt")
+	}
+	if fired, _ := walk(t, next); len(fired) != 0 {
+		t.Errorf("walk with the new manifest fired %q", fired)
+	}
+}
+
+func TestManifest(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":    "*.log\n",
+		"a.txt":         "alpha",
+		"sub/b.txt":     "beta",
+		"sub/same.txt":  "alpha",
+		"sub/debug.log": "ignored",
+		"empty":         "",
+	})
+	if err := os.Symlink("a.txt", filepath.Join(root, "link")); err != nil {
+		t.Logf("symlinks unsupported: %v", err)
+	}
+
+	// Digests as given by sha256sum.
+	const (
+		alpha = "8ed3f6ad685b959ead7022518e1af76cd816f8e8ec7ccdda1ed4018e8f2223f8"
+		beta  = "f44e64e75f3948e9f73f8dfa94721c4ce8cbb4f265c4790c702b2d41cfbf2753"
+		empty = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	)
+	want := map[string]string{
+		"a.txt":        alpha,
+		"sub/b.txt":    beta,
+		"sub/same.txt": alpha,
+		"empty":        empty,
+	}
+	got, err := Manifest(root)
+	if err != nil {
+		t.Fatalf("Manifest() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Manifest() = %v, want %v", got, want)
+	}
+
+	// Digests depend on contents alone.
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(root, "a.txt"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	if again, err := Manifest(root); err != nil || !reflect.DeepEqual(again, got) {
+		t.Errorf("Manifest() after touching a.txt = %v, %v; want %v", again, err, got)
+	}
+}
+
+func TestManifestMaxTotalBytes(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"a.txt":     "alpha",
+		"b.txt":     "bravo",
+		"sub/c.txt": "charlie",
+	})
+
+	// The cap falls partway through b.txt, which is left out unless
+	// truncated.
+	for _, tt := range []struct {
+		truncate bool
+		want     int
+	}{{false, 1}, {true, 2}} {
+		got, err := Manifest(root, WithMaxTotalBytes(8), WithTruncateAtCap(tt.truncate))
+		if err != nil {
+			t.Fatalf("Manifest() error = %v", err)
+		}
+		if len(got) != tt.want {
+			t.Errorf("Manifest() truncating %v has %d entries, want %d: %v", tt.truncate, len(got), tt.want, got)
+		}
+		if sum := sha256.Sum256([]byte("bra")); tt.truncate && got["b.txt"] != hex.EncodeToString(sum[:]) {
+			t.Errorf("Manifest()[b.txt] = %s, want the digest of its first 3 bytes", got["b.txt"])
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package walkrepo
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// errAllDone stops the walk of WalkRepoMulti once every callback has failed
+// or asked to stop.
+var errAllDone = errors.New("walkrepo: all callbacks done")
+
+// WalkRepoMulti walks root once, as WalkRepo does, passing each entry to
+// every one of fns in turn, so that several independent consumers can share
+// a walk. A callback which returns filepath.SkipDir for a directory is passed
+// none of its contents, or for a file, none of the rest of its directory; one
+// which returns filepath.SkipAll is passed no further entries. The walk only
+// skips what every callback does, and ends once none wants more.
+//
+// By default the first error returned by any callback aborts the walk and is
+// returned. With WithCollectErrors, a failing callback is passed no further
+// entries but the others carry on, and the errors of all are returned
+// together, joined by errors.Join.
+//
+// The callbacks are taken as a slice, rather than variadically, so that
+// options can follow them as they do in the package's other functions.
+func WalkRepoMulti(root string, fns []filepath.WalkFunc, opts ...Option) error {
+	cfg := newConfig(opts)
+	skipped := make([]map[string]bool, len(fns)) // directories each callback skipped
+	stopped := make([]bool, len(fns))            // by SkipAll
+	errs := make([]error, len(fns))
+	within := func(path string, dirs map[string]bool) bool {
+		for dir := filepath.Dir(path); len(dirs) > 0; dir = filepath.Dir(dir) {
+			if dirs[dir] {
+				return true
+			}
+			if dir == filepath.Dir(dir) {
+				break
+			}
+		}
+		return false
+	}
+
+	err := WalkRepo(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		active, skips := 0, 0
+		for i, fn := range fns {
+			if errs[i] != nil || stopped[i] || within(path, skipped[i]) {
+				continue
+			}
+			active++
+			switch err := fn(path, info, nil); {
+			case err == filepath.SkipDir:
+				dir := path
+				if !info.IsDir() {
+					dir = filepath.Dir(path)
+				}
+				if skipped[i] == nil {
+					skipped[i] = make(map[string]bool)
+				}
+				skipped[i][dir] = true
+				skips++
+			case err == filepath.SkipAll:
+				stopped[i] = true
+				skips++
+			case err != nil:
+				if !cfg.collectErrors {
+					return err
+				}
+				errs[i] = err
+			}
+		}
+		done := 0
+		for i := range fns {
+			if errs[i] != nil || stopped[i] {
+				done++
+			}
+		}
+		if done == len(fns) {
+			return errAllDone
+		}
+		if active > 0 && skips == active {
+			// No callback wants the directory's contents, or the rest of the
+			// file's directory.
+			return filepath.SkipDir
+		}
+		return nil
+	}, opts...)
+	if err != nil && err != errAllDone {
+		return err
+	}
+	return errors.Join(errs...)
+}
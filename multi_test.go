@@ -0,0 +1,145 @@
+package walkrepo
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWalkRepoMulti(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":  "*.log\n",
+		"a.txt":       "content",
+		"a.log":       "content",
+		"sub/b.txt":   "content",
+		"sub/c/d.txt": "content",
+		"zz/e.txt":    "content",
+	})
+
+	// recorder returns a callback recording the entries it is passed, and
+	// returning what result returns for them.
+	recorder := func(seen map[string]bool, result func(rel string) error) filepath.WalkFunc {
+		return func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, _ := filepath.Rel(root, path)
+			rel = filepath.ToSlash(rel)
+			seen[rel] = true
+			if result != nil {
+				return result(rel)
+			}
+			return nil
+		}
+	}
+	all := map[string]bool{"a.txt": true, "sub": true, "sub/b.txt": true, "sub/c": true, "sub/c/d.txt": true, "zz": true, "zz/e.txt": true}
+
+	t.Run("every callback receives every entry", func(t *testing.T) {
+		one, two := make(map[string]bool), make(map[string]bool)
+		if err := WalkRepoMulti(root, []filepath.WalkFunc{recorder(one, nil), recorder(two, nil)}); err != nil {
+			t.Fatalf("WalkRepoMulti() error = %v", err)
+		}
+		if !reflect.DeepEqual(one, all) || !reflect.DeepEqual(two, all) {
+			t.Errorf("callbacks received %v and %v, want %v", one, two, all)
+		}
+	})
+
+	t.Run("skipping a directory for one callback", func(t *testing.T) {
+		one, two := make(map[string]bool), make(map[string]bool)
+		skipSub := func(rel string) error {
+			if rel == "sub" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if err := WalkRepoMulti(root, []filepath.WalkFunc{recorder(one, skipSub), recorder(two, nil)}); err != nil {
+			t.Fatalf("WalkRepoMulti() error = %v", err)
+		}
+		want := map[string]bool{"a.txt": true, "sub": true, "zz": true, "zz/e.txt": true}
+		if !reflect.DeepEqual(one, want) {
+			t.Errorf("skipping callback received %v, want %v", one, want)
+		}
+		if !reflect.DeepEqual(two, all) {
+			t.Errorf("other callback received %v, want %v", two, all)
+		}
+	})
+
+	errOne, errTwo := errors.New("one failed"), errors.New("two failed")
+	failAt := func(at string, err error) func(string) error {
+		return func(rel string) error {
+			if rel == at {
+				return err
+			}
+			return nil
+		}
+	}
+
+	t.Run("first error aborts", func(t *testing.T) {
+		one, two := make(map[string]bool), make(map[string]bool)
+		err := WalkRepoMulti(root, []filepath.WalkFunc{recorder(one, failAt("sub", errOne)), recorder(two, nil)}, WithDirSort(true))
+		if err != errOne {
+			t.Errorf("WalkRepoMulti() error = %v, want %v", err, errOne)
+		}
+		if two["sub"] || two["sub/b.txt"] {
+			t.Errorf("walk went on past the error: %v", two)
+		}
+	})
+
+	t.Run("collected errors", func(t *testing.T) {
+		one, two, three := make(map[string]bool), make(map[string]bool), make(map[string]bool)
+		err := WalkRepoMulti(root, []filepath.WalkFunc{
+			recorder(one, failAt("sub", errOne)),
+			recorder(two, failAt("sub/c", errTwo)),
+			recorder(three, nil),
+		}, WithDirSort(true), WithCollectErrors(true))
+		if !errors.Is(err, errOne) || !errors.Is(err, errTwo) {
+			t.Errorf("WalkRepoMulti() error = %v, want both callbacks' errors", err)
+		}
+		if one["sub/b.txt"] || two["sub/c/d.txt"] || two["zz"] {
+			t.Errorf("failed callbacks were passed later entries: %v, %v", one, two)
+		}
+		if !reflect.DeepEqual(three, all) {
+			t.Errorf("surviving callback received %v, want %v", three, all)
+		}
+	})
+
+	t.Run("skipping the rest of a directory or walk for one callback", func(t *testing.T) {
+		for _, collect := range []bool{false, true} {
+			one, two, three := make(map[string]bool), make(map[string]bool), make(map[string]bool)
+			err := WalkRepoMulti(root, []filepath.WalkFunc{
+				recorder(one, failAt("sub/b.txt", filepath.SkipAll)),
+				recorder(two, failAt("sub/b.txt", filepath.SkipDir)),
+				recorder(three, nil),
+			}, WithDirSort(true), WithCollectErrors(collect))
+			if err != nil {
+				t.Errorf("WalkRepoMulti() with collection %v error = %v", collect, err)
+			}
+			if want := map[string]bool{"a.txt": true, "sub": true, "sub/b.txt": true}; !reflect.DeepEqual(one, want) {
+				t.Errorf("callback returning SkipAll received %v, want %v", one, want)
+			}
+			if want := map[string]bool{"a.txt": true, "sub": true, "sub/b.txt": true, "zz": true, "zz/e.txt": true}; !reflect.DeepEqual(two, want) {
+				t.Errorf("callback returning SkipDir for a file received %v, want %v", two, want)
+			}
+			if !reflect.DeepEqual(three, all) {
+				t.Errorf("other callback received %v, want %v", three, all)
+			}
+		}
+	})
+
+	t.Run("every callback stopping", func(t *testing.T) {
+		one, two := make(map[string]bool), make(map[string]bool)
+		err := WalkRepoMulti(root, []filepath.WalkFunc{
+			recorder(one, failAt("a.txt", filepath.SkipAll)),
+			recorder(two, failAt("sub", filepath.SkipAll)),
+		}, WithDirSort(true), WithCollectErrors(true))
+		if err != nil {
+			t.Errorf("WalkRepoMulti() error = %v", err)
+		}
+		if want := map[string]bool{"a.txt": true, "sub": true}; !reflect.DeepEqual(two, want) {
+			t.Errorf("last callback to stop received %v, want %v", two, want)
+		}
+	})
+}
@@ -0,0 +1,676 @@
+package walkrepo
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+)
+
+// Option configures the behaviour of WalkRepo.
+type Option func(*config)
+
+// config is the set of behaviours selected by a caller's Options.
+type config struct {
+	ignoreFiles    []string            // names of the per-directory ignore files
+	baseFiles      []string            // paths of ignore files applied at the root
+	optionalBases  []string            // as baseFiles, but which may be missing
+	seedPatterns   []gitignore.Pattern // parsed patterns applied at the root
+	noIgnoreRules  bool                // set by WithIgnoreRules(false)
+	envPatterns    []string            // names of environment variables holding patterns
+	readPatterns   []SourcedPattern    // patterns read by WithPatternReader
+	readErr        error               // the first error of a WithPatternReader
+	simpleExcludes []string            // globs excluding base names, outside gitignore rules
+	ignoreCase     bool                // set by WithIgnoreCase
+	excludeRegexps []*regexp.Regexp    // expressions excluding relative paths, outside gitignore rules
+	extensions     map[string]bool     // lower-cased extensions of the files reported, if set
+	errorHandler   func(path string, err error) error
+
+	reportIgnoreFiles bool
+	maxIgnoreFileSize int64
+	hashContents      bool
+	maxTotalBytes     int64
+	truncateAtCap     bool
+	timeout           time.Duration
+
+	relativeTo       string
+	strictRelativeTo bool
+	absolutePaths    bool
+
+	discoverRoot bool
+	requireRepo  bool
+	respectIndex bool
+
+	globalExcludes bool
+	infoExclude    bool
+	skipGit        bool
+
+	followInternalSymlinks bool
+	resolvePaths           bool
+
+	dirExitHook   func(dir string)
+	emptyDirHook  func(dir string)
+	descendFilter func(dir string, info os.FileInfo) bool
+	traversal     Traversal
+	dirSort       bool
+	contentCache  bool
+
+	channelBuffer int
+	collectErrors bool
+	retries       int
+	retryBackoff  time.Duration
+
+	logger *slog.Logger
+
+	exportIgnore    bool
+	snapshotIgnores bool
+
+	partitionDirs bool
+	pathTemplate  string
+	domainPrefix  []string
+	// ignoredHook, if set, is called with each entry the rules ignore.
+	ignoredHook func(path string, d fs.DirEntry) error
+}
+
+func newConfig(opts []Option) *config {
+	cfg := &config{ignoreFiles: []string{".gitignore"}}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// WithIgnoreFiles sets the names of the per-directory ignore files, in place
+// of the default ".gitignore". Names may be globs in the syntax of
+// filepath.Match, such as ".*ignore". Where a directory holds several ignore
+// files, their patterns are layered in the order given here (and by name, for
+// files matching the same glob), so that later files take precedence.
+func WithIgnoreFiles(names ...string) Option {
+	return func(c *config) {
+		c.ignoreFiles = names
+	}
+}
+
+// WithIgnoreRules sets whether ignore rules are applied at all, which they
+// are by default. With rules disabled, no ignore file is read, nor are any
+// patterns contributed by options such as WithGlobalExcludes, and every entry
+// is walked, ignore files included, as by a plain filepath.Walk. Options which
+// don't concern ignore rules, such as WithSkipGit, WithSimpleExcludes and
+// WithDirSort, still apply.
+func WithIgnoreRules(apply bool) Option {
+	return func(c *config) {
+		c.noIgnoreRules = !apply
+	}
+}
+
+// WithBaseIgnoreFiles applies the patterns of the ignore files at paths,
+// which may lie outside the tree, as root-level rules beneath those of the
+// tree's own .gitignore files. They suit system-wide, global and team-shared
+// ignore files. The files are layered in the order given, so that later files
+// take precedence over earlier ones. As in git, where the repository's own
+// rules outrank the user's, the global excludes and .git/info/exclude take
+// precedence over them all. Missing files are skipped.
+func WithBaseIgnoreFiles(paths ...string) Option {
+	return func(c *config) {
+		c.optionalBases = append(c.optionalBases, paths...)
+	}
+}
+
+// WithEnvPatterns reads newline-separated patterns from the environment
+// variable called name, and applies them as though they were entries of the
+// root .gitignore. An unset or empty variable contributes no patterns.
+//
+// The patterns are applied beneath those of the repository's own .gitignore
+// files, so a .gitignore may still negate them.
+func WithEnvPatterns(name string) Option {
+	return func(c *config) {
+		c.envPatterns = append(c.envPatterns, name)
+	}
+}
+
+// WithPatternReader reads newline-separated patterns from r, as though they
+// were the contents of the root .gitignore, and applies them as the
+// environment's patterns are, so that `cat rules | tool` works as expected.
+// Their source is given as "<reader>".
+//
+// r is read to its end when the option is created, so one option may serve
+// several walks. If reading fails, every walk given the option fails with the
+// error.
+func WithPatternReader(r io.Reader) Option {
+	content, err := io.ReadAll(r)
+	var sps []SourcedPattern
+	if err != nil {
+		err = fmt.Errorf("walkrepo: reading patterns: %w", err)
+	} else {
+		sps = parsePatterns(string(content), "<reader>", nil)
+	}
+	return func(c *config) {
+		c.readPatterns = append(c.readPatterns, sps...)
+		if c.readErr == nil {
+			c.readErr = err
+		}
+	}
+}
+
+// WithIgnoreCase sets whether ignore patterns match without regard to case,
+// as git's do with core.ignoreCase, which it sets for repositories on
+// case-insensitive filesystems. The pattern "readme" then ignores README
+// and Readme too. Where names differing only in case coexist, as they can on
+// case-sensitive filesystems, each is still walked (or ignored) once, as an
+// entry in its own right.
+//
+// Patterns passed to WalkWithBasePatterns which are not this package's own,
+// such as go-git's, match as they would without the option.
+func WithIgnoreCase(ignore bool) Option {
+	return func(c *config) {
+		c.ignoreCase = ignore
+	}
+}
+
+// WithSimpleExcludes skips every entry whose base name matches one of globs,
+// in the syntax of filepath.Match, at any depth. Unlike ignore patterns they
+// are never anchored, and no negation in an ignore file can re-include what
+// they exclude. Skipped directories are not descended into.
+func WithSimpleExcludes(globs ...string) Option {
+	return func(c *config) {
+		c.simpleExcludes = append(c.simpleExcludes, globs...)
+	}
+}
+
+// WithExtensions limits the files reported to those whose extension, as
+// given by filepath.Ext, is one of exts, compared without regard to case.
+// Extensions may be given with or without their leading dot; "" selects
+// files with no extension. Directories are still reported and descended
+// into. Other files are passed over as soon as their directory is listed,
+// before any other work is done on them, making this cheaper than filtering
+// in the walk function.
+func WithExtensions(exts ...string) Option {
+	return func(c *config) {
+		if c.extensions == nil {
+			c.extensions = make(map[string]bool)
+		}
+		for _, ext := range exts {
+			if ext != "" && !strings.HasPrefix(ext, ".") {
+				ext = "." + ext
+			}
+			c.extensions[strings.ToLower(ext)] = true
+		}
+	}
+}
+
+// WithExcludeRegexp skips every entry whose path relative to the root, with
+// forward slashes (such as "sub/dir/file.go"), matches one of res, for
+// exclusions globs can't express. An expression matches anywhere in the path
+// unless anchored with ^ and $. As with WithSimpleExcludes, no negation in an
+// ignore file can re-include what they exclude, and skipped directories are
+// not descended into.
+func WithExcludeRegexp(res ...*regexp.Regexp) Option {
+	return func(c *config) {
+		c.excludeRegexps = append(c.excludeRegexps, res...)
+	}
+}
+
+// WithErrorHandler sets a handler for errors met while reading the entries
+// beneath the root, such as a directory which was removed after its parent
+// was listed. Returning nil from handler skips the entry and continues the
+// walk; returning an error aborts the walk with it.
+//
+// Without a handler, entries which no longer exist (or directories which are
+// no longer directories) are skipped and any other error aborts the walk.
+//
+// Errors carry the path of the entry they concern, as an *fs.PathError. Among
+// them are those of paths too long for the operating system, which can be met
+// in deeply nested trees and skipped by a handler like any other. A directory
+// whose ignore files cannot be read is skipped, if the handler allows it,
+// rather than walked without their rules.
+func WithErrorHandler(handler func(path string, err error) error) Option {
+	return func(c *config) {
+		c.errorHandler = handler
+	}
+}
+
+// WithReportIgnoreFiles sets whether the ignore files themselves are passed
+// to the walk function, which they are not by default. They are reported
+// after being parsed, and their rules apply either way.
+func WithReportIgnoreFiles(report bool) Option {
+	return func(c *config) {
+		c.reportIgnoreFiles = report
+	}
+}
+
+// WithMaxIgnoreFileSize limits the size of the ignore files read from the
+// tree to n bytes, guarding against pathological files which would take much
+// memory and time to parse. A larger ignore file is not read; instead its
+// directory fails with an error wrapping ErrIgnoreFileTooLarge, which aborts
+// the walk unless a handler given to WithErrorHandler skips the directory.
+// Either way, no entry is walked without the rules it may be subject to. An n
+// of zero or less sets no limit.
+func WithMaxIgnoreFileSize(n int64) Option {
+	return func(c *config) {
+		c.maxIgnoreFileSize = n
+	}
+}
+
+// WithHashContents sets whether TreeHash hashes the contents of each file,
+// rather than its size and modification time. Hashing contents is slower, but
+// is unaffected by files being touched or copied without changing.
+func WithHashContents(hashContents bool) Option {
+	return func(c *config) {
+		c.hashContents = hashContents
+	}
+}
+
+// WithMaxTotalBytes caps the content read by walks which read the files
+// they visit, such as TreeHash with WithHashContents and Manifest, at n bytes
+// in all. Once the cap is reached no more is read, and the files not yet read
+// are left out. An n of zero or less sets no cap.
+func WithMaxTotalBytes(n int64) Option {
+	return func(c *config) {
+		c.maxTotalBytes = n
+	}
+}
+
+// WithTruncateAtCap sets whether the file being read when the cap set by
+// WithMaxTotalBytes is reached is included, cut short at the cap, rather than
+// left out with the files after it.
+func WithTruncateAtCap(truncate bool) Option {
+	return func(c *config) {
+		c.truncateAtCap = truncate
+	}
+}
+
+// WithTimeout limits the walk to the duration d, after which it is aborted
+// with an error wrapping ErrTimeout. A d of zero or less sets no limit.
+func WithTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.timeout = d
+	}
+}
+
+// WithRelativeTo makes the paths passed to the walk function relative to
+// base (typically the working directory) rather than joined onto the root.
+// Paths beside or above base are given with ".." components. Where no
+// relative path exists, as between Windows volumes, absolute paths are
+// reported instead, unless WithStrictRelativeTo is set.
+func WithRelativeTo(base string) Option {
+	return func(c *config) {
+		c.relativeTo = base
+	}
+}
+
+// WithStrictRelativeTo sets whether a walk fails, rather than reporting
+// absolute paths, when paths cannot be made relative to the base given to
+// WithRelativeTo.
+func WithStrictRelativeTo(strict bool) Option {
+	return func(c *config) {
+		c.strictRelativeTo = strict
+	}
+}
+
+// WithAbsolutePaths sets whether the paths passed to the walk function (and
+// to hooks such as WithDirExitHook's and WithErrorHandler's) are absolute,
+// even when the root is given as a relative path. They are the root made
+// absolute, as by filepath.Abs, joined with the entries' paths beneath it.
+// WithRelativeTo takes precedence over it.
+func WithAbsolutePaths(absolute bool) Option {
+	return func(c *config) {
+		c.absolutePaths = absolute
+	}
+}
+
+// WithDiscoverRoot sets whether the walk starts from the root of the git
+// repository enclosing the given root, as found by FindRepoRoot, rather than
+// from the given root itself. Reported paths are then absolute. If the root
+// is not within a repository, the walk starts from the root itself, unless
+// WithRequireRepo is set.
+func WithDiscoverRoot(discover bool) Option {
+	return func(c *config) {
+		c.discoverRoot = discover
+	}
+}
+
+// WithRequireRepo sets whether a walk fails, with an error wrapping
+// ErrNoRepo, when the root lies within no git repository, as found by
+// FindRepoRoot. Outside a repository, options such as WithInfoExclude have
+// nothing to apply, and a walk would otherwise quietly go ahead without them.
+func WithRequireRepo(require bool) Option {
+	return func(c *config) {
+		c.requireRepo = require
+	}
+}
+
+// WithRespectIndex sets whether files tracked in git's index are walked even
+// where ignore patterns match them, as git itself never ignores a tracked
+// file. The index is read, once per walk, from the repository enclosing the
+// root, as found by FindRepoRoot. An ignored directory containing tracked
+// files is walked for them, but the untracked entries within it stay ignored.
+// Outside a repository, or before anything is added to its index, nothing is
+// tracked.
+func WithRespectIndex(respect bool) Option {
+	return func(c *config) {
+		c.respectIndex = respect
+	}
+}
+
+// WithGlobalExcludes sets whether the user's global excludes file (git's
+// core.excludesFile, by default $XDG_CONFIG_HOME/git/ignore) is applied as
+// root-level rules. A missing file is treated as empty.
+func WithGlobalExcludes(global bool) Option {
+	return func(c *config) {
+		c.globalExcludes = global
+	}
+}
+
+// WithInfoExclude sets whether the .git/info/exclude file of the repository
+// enclosing the root, as found by FindRepoRoot, is applied as root-level
+// rules. Its patterns anchor at the repository's root, so that walking a
+// subdirectory ignores what git would there. A missing file is treated as
+// empty.
+func WithInfoExclude(infoExclude bool) Option {
+	return func(c *config) {
+		c.infoExclude = infoExclude
+	}
+}
+
+// WithSkipGit sets whether .git directories (and files) are skipped, along
+// with their contents.
+func WithSkipGit(skip bool) Option {
+	return func(c *config) {
+		c.skipGit = skip
+	}
+}
+
+// WithFollowInternalSymlinks sets whether symlinks are followed when their
+// targets lie within the root, so that a linked directory is descended into
+// and a linked file reported as the file it links to, under the link's path.
+// Symlinks leading outside the root, dangling symlinks, and those which would
+// lead the walk around a cycle are reported as symlinks and not followed.
+func WithFollowInternalSymlinks(follow bool) Option {
+	return func(c *config) {
+		c.followInternalSymlinks = follow
+	}
+}
+
+// WithResolvePaths sets whether the entries given by WalkRepoEntries and
+// WalkRepoChan carry their real paths, with symlinks resolved as by
+// filepath.EvalSymlinks, alongside the paths by which the walk reached them.
+// Callers can then tell when two paths lead to the same file. Resolving costs
+// a syscall per path component, so is off by default.
+func WithResolvePaths(resolve bool) Option {
+	return func(c *config) {
+		c.resolvePaths = resolve
+	}
+}
+
+// WithDirExitHook sets a function called with the path of each directory
+// once all of its children have been walked, giving a post-order counterpart
+// to the walk function's pre-order visits. It is called for the root too, last
+// of all, but not for directories skipped with filepath.SkipDir nor for any
+// directory when the walk is aborted.
+func WithDirExitHook(hook func(dir string)) Option {
+	return func(c *config) {
+		c.dirExitHook = hook
+	}
+}
+
+// WithEmptyDirCallback sets a function called with the path of each walked
+// directory, the root included, which turned out to have no entries passed to
+// the walk function: one which is empty, or whose entries are all ignored.
+// Such directories hold nothing git would track, unless an ignore file is
+// among their entries; ignore files count as entries only if reported, with
+// WithReportIgnoreFiles. fn is called once the directory's entries have all
+// been visited, before the hook given to WithDirExitHook.
+func WithEmptyDirCallback(fn func(dir string)) Option {
+	return func(c *config) {
+		c.emptyDirHook = fn
+	}
+}
+
+// WithDescendFilter sets a filter consulted before descending into each
+// directory which is not ignored, once the directory has been passed to the
+// walk function. Where filter returns false, the directory's contents are not
+// walked, as if the walk function had returned filepath.SkipDir for it. filter
+// is given the directory's path, as reported to the walk function, and its
+// FileInfo; it may be called more than once for a directory, as with
+// WithSnapshotIgnores, so should have no side effects.
+func WithDescendFilter(filter func(dir string, info os.FileInfo) bool) Option {
+	return func(c *config) {
+		c.descendFilter = filter
+	}
+}
+
+// WithContentCache sets whether a Walker caches the patterns of ignore files
+// by their content, so that files with identical content (such as copies of
+// the same boilerplate .gitignore) are parsed only once across its walks. A
+// file's content is still read whenever its size or modification time has
+// changed, but is parsed again only if it hasn't been seen before.
+func WithContentCache(byContent bool) Option {
+	return func(c *config) {
+		c.contentCache = byContent
+	}
+}
+
+// WithChannelBuffer sets the capacity of the channel on which WalkRepoChan
+// sends entries, letting the walk run up to n entries ahead of its consumer.
+func WithChannelBuffer(n int) Option {
+	return func(c *config) {
+		c.channelBuffer = n
+	}
+}
+
+// WithCollectErrors sets whether WalkRepoMulti carries on past a callback's
+// error, passing entries to the callbacks which haven't failed and returning
+// the errors of all those which have, rather than aborting at the first.
+func WithCollectErrors(collect bool) Option {
+	return func(c *config) {
+		c.collectErrors = collect
+	}
+}
+
+// WithRetries retries the listing of a directory up to n times when it
+// fails with a transient error, such as EAGAIN or EINTR from a network
+// filesystem, before handling the error as usual. The first retry waits
+// backoff, and each later one twice as long as the last. Other errors, such
+// as a directory which doesn't exist or can't be read for want of
+// permission, are never retried. An n of zero or less disables retries.
+func WithRetries(n int, backoff time.Duration) Option {
+	return func(c *config) {
+		c.retries = n
+		c.retryBackoff = backoff
+	}
+}
+
+// WithLogger sets a logger to which the walk writes debug-level records as it
+// enters each directory, decides whether each entry is ignored, and meets
+// errors. Records carry the entry's path, and for ignore decisions, whether it
+// was ignored and the pattern which decided it (empty if none matched).
+// Without a logger, nothing is logged.
+func WithLogger(l *slog.Logger) Option {
+	return func(c *config) {
+		c.logger = l
+	}
+}
+
+// WithExportIgnore sets whether paths given the export-ignore attribute by
+// .gitattributes files are skipped, as git archive leaves them out of
+// archives. Such paths are skipped whatever the ignore files say, and
+// directories marked export-ignore are not descended into.
+func WithExportIgnore(exportIgnore bool) Option {
+	return func(c *config) {
+		c.exportIgnore = exportIgnore
+	}
+}
+
+// WithSnapshotIgnores sets whether the ignore files of the whole tree are
+// read before the walk begins, so that the walk applies one consistent set of
+// rules even if ignore files are edited while it is in progress. This costs
+// an extra traversal of the directories, though not of their ignored
+// contents.
+func WithSnapshotIgnores(snapshot bool) Option {
+	return func(c *config) {
+		c.snapshotIgnores = snapshot
+	}
+}
+
+// WithDirSort sets whether the entries of each directory are sorted by name
+// before they are visited, rather than visited in the order the filesystem
+// lists them. Only siblings are ordered: no more than one directory's listing
+// is held at once, so where a total order over the tree is needed, paths must
+// be collected and sorted after the walk.
+//
+// Under DepthFirst this makes the walk deterministic, with each directory's
+// contents following it directly; under BreadthFirst, each depth is visited in
+// the order of its directories, each directory's entries in name order.
+func WithDirSort(sorted bool) Option {
+	return func(c *config) {
+		c.dirSort = sorted
+	}
+}
+
+// WithPartitionDirs sets whether Partition lists directories, as well as
+// files, among the kept and ignored paths.
+func WithPartitionDirs(include bool) Option {
+	return func(c *config) {
+		c.partitionDirs = include
+	}
+}
+
+// WithPathTemplate sets the template by which WalkRepoFormatted formats the
+// path of each file, in place of the default "{rel}". Each token in tmpl is
+// replaced by a part of the path:
+//
+//	{rel}   the path relative to the root
+//	{abs}   the absolute path
+//	{name}  the base name
+//	{ext}   the extension, with its dot, or "" if there is none
+//	{dir}   the directory relative to the root, or "." for the root itself
+//
+// Paths are given with the operating system's separators. Any other text,
+// braces included, is kept as it is.
+func WithPathTemplate(tmpl string) Option {
+	return func(c *config) {
+		c.pathTemplate = tmpl
+	}
+}
+
+// WithDomainPrefix places the root of a walk at segments beneath a logical
+// root, as when the tree is mounted within a larger virtual filesystem. The
+// segments are prepended to the path of every entry as it is matched, so
+// that patterns applied at the root, such as those given to
+// WalkWithBasePatterns or WithBaseIgnoreFiles, anchor at the logical root:
+// with a prefix of "vendor", the pattern "/vendor/lib" excludes the walked
+// root's lib. Patterns of the ignore files within the tree anchor at their
+// own directories as usual. Reported paths are unaffected.
+func WithDomainPrefix(segments []string) Option {
+	return func(c *config) {
+		c.domainPrefix = append([]string(nil), segments...)
+	}
+}
+
+// withIgnoredHook sets a function called with each entry which is ignored,
+// rather than passed to the walk function. An error returned from hook aborts
+// the walk.
+func withIgnoredHook(hook func(path string, d fs.DirEntry) error) Option {
+	return func(c *config) {
+		c.ignoredHook = hook
+	}
+}
+
+// Traversal is an order in which a walk visits entries.
+type Traversal int
+
+const (
+	// DepthFirst visits each directory's contents directly after the
+	// directory itself, before its later siblings. It is the default.
+	DepthFirst Traversal = iota
+	// BreadthFirst visits every entry at one depth before any entry at the
+	// next.
+	BreadthFirst
+)
+
+// WithTraversal sets the order in which entries are visited. Entries within
+// a directory are visited in the order they are listed, whichever the order.
+//
+// With BreadthFirst, the hook given to WithDirExitHook is called once each
+// directory's own entries have been visited, before those of its
+// subdirectories.
+func WithTraversal(order Traversal) Option {
+	return func(c *config) {
+		c.traversal = order
+	}
+}
+
+// excludesExtension reports whether d is a file which WithExtensions
+// excludes.
+func (c *config) excludesExtension(d fs.DirEntry) bool {
+	return c.extensions != nil && !d.IsDir() && !c.extensions[strings.ToLower(filepath.Ext(d.Name()))]
+}
+
+// basePatterns returns the root-level patterns contributed by the options,
+// which are applied before any .gitignore found in the tree. In increasing
+// order of precedence, they are the files given to WithBaseIgnoreFiles, the
+// global excludes, .git/info/exclude, any other extra ignore files, any
+// pre-parsed patterns, the environment's patterns and then those read by
+// WithPatternReader.
+func (c *config) basePatterns(root string) ([]SourcedPattern, error) {
+	if c.noIgnoreRules {
+		return nil, nil
+	}
+	if c.readErr != nil {
+		return nil, c.readErr
+	}
+	var sps []SourcedPattern
+	for _, path := range c.optionalBases {
+		filePatterns, err := readOptionalPatterns(path)
+		if err != nil {
+			return nil, err
+		}
+		sps = append(sps, filePatterns...)
+	}
+	if c.globalExcludes {
+		path, err := globalExcludesFile()
+		if err != nil {
+			return nil, err
+		}
+		if path != "" {
+			filePatterns, err := readOptionalPatterns(path)
+			if err != nil {
+				return nil, err
+			}
+			sps = append(sps, filePatterns...)
+		}
+	}
+	if c.infoExclude {
+		filePatterns, err := infoExcludePatterns(root)
+		if err != nil {
+			return nil, err
+		}
+		sps = append(sps, filePatterns...)
+	}
+	for _, path := range c.baseFiles {
+		filePatterns, err := parseFilePatterns(path, nil)
+		if err != nil {
+			return nil, err
+		}
+		sps = append(sps, filePatterns...)
+	}
+	for _, p := range c.seedPatterns {
+		sp, ok := p.(SourcedPattern)
+		if !ok {
+			sp = SourcedPattern{Pattern: p}
+		}
+		sps = append(sps, sp)
+	}
+	for _, name := range c.envPatterns {
+		sps = append(sps, parsePatterns(os.Getenv(name), "$"+name, nil)...)
+	}
+	sps = append(sps, c.readPatterns...)
+	return sps, nil
+}
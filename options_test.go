@@ -0,0 +1,1223 @@
+package walkrepo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"syscall"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestWithEnvPatterns(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":      "!keep.tmp",
+		"main.go":         "content",
+		"scratch.tmp":     "content",
+		"keep.tmp":        "content",
+		"dist/bundle.js":  "content",
+		"sub/notes.tmp":   "content",
+		"sub/dist/app.js": "content",
+		"sub/readme.md":   "content",
+	})
+	t.Setenv("WALKREPO_TEST_EXCLUDES", "*.tmp\n/dist/\n")
+
+	walked := walkedPaths(t, root, WithEnvPatterns("WALKREPO_TEST_EXCLUDES"))
+	assertWalked(t, walked,
+		[]string{"main.go", "keep.tmp", "sub", "sub/dist", "sub/dist/app.js", "sub/readme.md"},
+		[]string{"scratch.tmp", "dist", "dist/bundle.js", "sub/notes.tmp"},
+	)
+
+	t.Run("unset variable", func(t *testing.T) {
+		walked := walkedPaths(t, root, WithEnvPatterns("WALKREPO_TEST_UNSET"))
+		assertWalked(t, walked, []string{"scratch.tmp", "dist/bundle.js", "sub/notes.tmp"}, nil)
+	})
+}
+
+func TestWithPatternReader(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":      "!keep.tmp",
+		"main.go":         "content",
+		"scratch.tmp":     "content",
+		"keep.tmp":        "content",
+		"dist/bundle.js":  "content",
+		"sub/notes.tmp":   "content",
+		"sub/dist/app.js": "content",
+		"sub/debug.log":   "content",
+		"sub/readme.md":   "content",
+	})
+
+	opt := WithPatternReader(strings.NewReader("# piped in\n*.tmp\n/dist/\n\n*.log\n"))
+	want := []string{"main.go", "keep.tmp", "sub", "sub/dist", "sub/dist/app.js", "sub/readme.md"}
+	notWant := []string{"scratch.tmp", "dist", "dist/bundle.js", "sub/notes.tmp", "sub/debug.log"}
+	assertWalked(t, walkedPaths(t, root, opt), want, notWant)
+
+	// The reader was consumed once, when the option was created, and the
+	// option still applies to later walks.
+	assertWalked(t, walkedPaths(t, root, opt), want, notWant)
+
+	t.Run("read error", func(t *testing.T) {
+		boom := errors.New("boom")
+		err := WalkRepo(root, func(path string, info os.FileInfo, err error) error {
+			return err
+		}, WithPatternReader(io.MultiReader(strings.NewReader("*.tmp\n"), iotest.ErrReader(boom))))
+		if !errors.Is(err, boom) {
+			t.Errorf("WalkRepo() error = %v, want %v", err, boom)
+		}
+	})
+}
+
+func TestWithExtensions(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":     "vendor/\ngen.go\n",
+		"main.go":        "content",
+		"gen.go":         "content",
+		"README.MD":      "content",
+		"Makefile":       "content",
+		"docs/guide.md":  "content",
+		"docs/logo.png":  "content",
+		"sub/notes.go.t": "content",
+		"sub/deep/a.Go":  "content",
+		"vendor/lib.go":  "content",
+	})
+
+	walked := walkedPaths(t, root, WithExtensions(".go", "md"), WithReportIgnoreFiles(true))
+	assertWalked(t, walked,
+		[]string{"main.go", "README.MD", "docs", "docs/guide.md", "sub", "sub/deep", "sub/deep/a.Go"},
+		[]string{"gen.go", "Makefile", "docs/logo.png", "sub/notes.go.t", "vendor", "vendor/lib.go", ".gitignore"},
+	)
+	if len(walked) != 7 {
+		t.Errorf("walked %v, want only the matching files and their directories", walked)
+	}
+
+	walked = walkedPaths(t, root, WithExtensions(""))
+	assertWalked(t, walked, []string{"Makefile", "docs", "sub/deep"}, []string{"main.go", "README.MD", "docs/guide.md"})
+
+	t.Run("followed symlinks", func(t *testing.T) {
+		for link, target := range map[string]string{"linked": "docs", "alias.go": "main.go", "alias": "main.go"} {
+			if err := os.Symlink(target, filepath.Join(root, link)); err != nil {
+				t.Skipf("symlinks unsupported: %v", err)
+			}
+		}
+		walked := walkedPaths(t, root, WithExtensions(".md", ".go"), WithFollowInternalSymlinks(true))
+		assertWalked(t, walked, []string{"linked", "linked/guide.md", "alias.go"}, []string{"alias", "linked/logo.png"})
+	})
+}
+
+func TestWithIgnoreCase(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":   "readme\n/docs/\n!readme.md\nsub/build/\n*.log\n!KEEP.log\n",
+		"README":       "content",
+		"README.md":    "content",
+		"Docs/a.txt":   "content",
+		"Sub/Build/x":  "content",
+		"sub/a.log":    "content",
+		"sub/keep.LOG": "content",
+	})
+	// Names differing only in case can only coexist on case-sensitive
+	// filesystems, such as Linux's, where git leaves core.ignoreCase unset
+	// but may be asked to set it.
+	collide := map[string]string{"readme": "content", "docs": "content"}
+	if _, err := os.Stat(filepath.Join(root, "readme")); err == nil {
+		collide = nil
+	}
+	writeTree(t, root, collide)
+
+	walk := func(opts ...Option) map[string]int {
+		counts := make(map[string]int)
+		err := WalkRepo(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, _ := filepath.Rel(root, path)
+			counts[filepath.ToSlash(rel)]++
+			return nil
+		}, opts...)
+		if err != nil {
+			t.Fatalf("WalkRepo() error = %v", err)
+		}
+		return counts
+	}
+
+	// Expectations taken from `git status --ignored` over the same tree,
+	// with core.ignoreCase set and unset.
+	want := map[string]int{"README.md": 1, "Sub": 1, "sub": 1, "sub/keep.LOG": 1}
+	if collide != nil {
+		want["docs"] = 1
+	}
+	if got := walk(WithIgnoreCase(true)); !reflect.DeepEqual(got, want) {
+		t.Errorf("WithIgnoreCase(true) walked %v, want %v", got, want)
+	}
+
+	want = map[string]int{"README": 1, "README.md": 1, "Docs": 1, "Docs/a.txt": 1,
+		"Sub": 1, "Sub/Build": 1, "Sub/Build/x": 1, "sub": 1, "sub/keep.LOG": 1}
+	if collide != nil {
+		want["docs"] = 1
+	}
+	if got := walk(); !reflect.DeepEqual(got, want) {
+		t.Errorf("WithIgnoreCase(false) walked %v, want %v", got, want)
+	}
+
+	results, err := CheckIgnore(root, []string{"README", "Docs/a.txt", "sub/keep.LOG"}, WithIgnoreCase(true))
+	if err != nil {
+		t.Fatalf("CheckIgnore() error = %v", err)
+	}
+	for i, ignored := range []bool{true, true, false} {
+		if results[i].Ignored != ignored {
+			t.Errorf("CheckIgnore(%q) = %+v, want ignored %v", results[i].Path, results[i], ignored)
+		}
+	}
+}
+
+func TestWithReportIgnoreFiles(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":     "*.log\n.*\n",
+		"sub/.gitignore": "*.tmp",
+		"sub/a.tmp":      "content",
+		"sub/b.txt":      "content",
+		"c.log":          "content",
+	})
+
+	walked := walkedPaths(t, root)
+	assertWalked(t, walked, []string{"sub", "sub/b.txt"}, []string{".gitignore", "sub/.gitignore", "c.log", "sub/a.tmp"})
+
+	walked = walkedPaths(t, root, WithReportIgnoreFiles(true))
+	assertWalked(t, walked,
+		[]string{".gitignore", "sub/.gitignore", "sub", "sub/b.txt"},
+		[]string{"c.log", "sub/a.tmp"},
+	)
+}
+
+func TestWithIgnoreRules(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":       "*.log\nbuild/\n",
+		"a.txt":            "content",
+		"a.log":            "content",
+		"build/out.bin":    "content",
+		"sub/.gitignore":   "*.tmp",
+		"sub/b.tmp":        "content",
+		"sub/node_modules": "content",
+		".git/HEAD":        "content",
+	})
+	t.Setenv("WALKREPO_TEST_PATTERNS", "a.txt")
+
+	walked := walkedPaths(t, root, WithEnvPatterns("WALKREPO_TEST_PATTERNS"))
+	assertWalked(t, walked, []string{"sub"}, []string{"a.txt", "a.log", "build", "sub/b.tmp", ".gitignore"})
+
+	walked = walkedPaths(t, root,
+		WithIgnoreRules(false),
+		WithEnvPatterns("WALKREPO_TEST_PATTERNS"),
+		WithSkipGit(true),
+		WithSimpleExcludes("node_modules"),
+	)
+	want := map[string]bool{
+		".gitignore":     true,
+		"a.txt":          true,
+		"a.log":          true,
+		"build":          true,
+		"build/out.bin":  true,
+		"sub":            true,
+		"sub/.gitignore": true,
+		"sub/b.tmp":      true,
+	}
+	if !reflect.DeepEqual(walked, want) {
+		t.Errorf("with ignore rules disabled, walked %v, want %v", walked, want)
+	}
+}
+
+func TestWithMaxIgnoreFileSize(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":     "*.log\n",
+		"a.txt":          "content",
+		"a.log":          "content",
+		"big/.gitignore": strings.Repeat("pattern\n", 1000),
+		"big/b.txt":      "content",
+		"small/c.txt":    "content",
+	})
+	bigIgnore := filepath.Join(root, "big", ".gitignore")
+
+	walked := walkedPaths(t, root, WithMaxIgnoreFileSize(8000))
+	assertWalked(t, walked, []string{"a.txt", "big/b.txt", "small/c.txt"}, []string{"a.log"})
+
+	err := WalkRepo(root, func(path string, info os.FileInfo, err error) error {
+		return err
+	}, WithMaxIgnoreFileSize(7999))
+	var pathErr *fs.PathError
+	if !errors.Is(err, ErrIgnoreFileTooLarge) || !errors.As(err, &pathErr) || pathErr.Path != bigIgnore {
+		t.Errorf("WalkRepo() error = %v, want ErrIgnoreFileTooLarge for %s", err, bigIgnore)
+	}
+
+	// A handler may skip the directory with the oversized ignore file.
+	var handled []string
+	walked = walkedPaths(t, root, WithMaxIgnoreFileSize(7999), WithErrorHandler(func(path string, err error) error {
+		if !errors.Is(err, ErrIgnoreFileTooLarge) {
+			return err
+		}
+		handled = append(handled, path)
+		return nil
+	}))
+	assertWalked(t, walked, []string{"a.txt", "big", "small/c.txt"}, []string{"a.log", "big/b.txt"})
+	if want := []string{filepath.Join(root, "big")}; !reflect.DeepEqual(handled, want) {
+		t.Errorf("error handler called for %q, want %q", handled, want)
+	}
+}
+
+func TestWithIgnoreFiles(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":        "*.log\n!keep.tmp",
+		".dockerignore":     "*.tmp\n!important.log",
+		"app.log":           "content",
+		"important.log":     "content",
+		"scratch.tmp":       "content",
+		"keep.tmp":          "content",
+		"main.go":           "content",
+		"sub/.dockerignore": "*.go",
+		"sub/lib.go":        "content",
+		"sub/lib.txt":       "content",
+	})
+
+	t.Run("layered in configured order", func(t *testing.T) {
+		// .dockerignore is configured last, so its rules take precedence.
+		walked := walkedPaths(t, root, WithIgnoreFiles(".gitignore", ".dockerignore"))
+		assertWalked(t, walked,
+			[]string{"important.log", "main.go", "sub/lib.txt"},
+			[]string{".gitignore", ".dockerignore", "sub/.dockerignore", "app.log", "scratch.tmp", "keep.tmp", "sub/lib.go"},
+		)
+	})
+
+	t.Run("reversed order", func(t *testing.T) {
+		walked := walkedPaths(t, root, WithIgnoreFiles(".dockerignore", ".gitignore"))
+		assertWalked(t, walked,
+			[]string{"keep.tmp", "main.go", "sub/lib.txt"},
+			[]string{"app.log", "important.log", "scratch.tmp", "sub/lib.go"},
+		)
+	})
+
+	t.Run("gitignore alone by default", func(t *testing.T) {
+		walked := walkedPaths(t, root)
+		assertWalked(t, walked,
+			[]string{".dockerignore", "scratch.tmp", "keep.tmp", "sub/lib.go"},
+			[]string{".gitignore", "app.log", "important.log"},
+		)
+	})
+}
+
+func TestWithIgnoreFilesGlob(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".dockerignore":  "*.tar",
+		".eslintignore":  "*.min.js\n!vendor.tar",
+		".gitignore":     "*.log",
+		".ignorerc":      "*.go",
+		"app.log":        "content",
+		"image.tar":      "content",
+		"vendor.tar":     "content",
+		"bundle.min.js":  "content",
+		"main.go":        "content",
+		"sub/.npmignore": "*.md",
+		"sub/readme.md":  "content",
+		"sub/index.js":   "content",
+	})
+
+	walked := walkedPaths(t, root, WithIgnoreFiles(".*ignore"))
+	assertWalked(t, walked,
+		// .ignorerc doesn't match the glob, so is walked and has no effect.
+		[]string{".ignorerc", "main.go", "vendor.tar", "sub/index.js"},
+		[]string{".dockerignore", ".eslintignore", ".gitignore", "sub/.npmignore", "app.log", "image.tar", "bundle.min.js", "sub/readme.md"},
+	)
+}
+
+func TestWithTimeout(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{}
+	for i := 0; i < 20; i++ {
+		files[fmt.Sprintf("dir%d/file.txt", i)] = "content"
+	}
+	writeTree(t, root, files)
+
+	// Slow every directory listing down, so the walk can't finish in time.
+	orig := readDir
+	readDir = func(path string) ([]fs.DirEntry, error) {
+		time.Sleep(10 * time.Millisecond)
+		return orig(path)
+	}
+	t.Cleanup(func() { readDir = orig })
+
+	dirs := 0
+	err := WalkRepo(root, func(path string, info os.FileInfo, err error) error {
+		if info.IsDir() {
+			dirs++
+		}
+		return err
+	}, WithTimeout(35*time.Millisecond))
+	if !errors.Is(err, ErrTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("WalkRepo() error = %v, want ErrTimeout", err)
+	}
+	if dirs >= 20 {
+		t.Errorf("walk visited all %d directories despite the timeout", dirs)
+	}
+
+	if err := WalkRepo(root, func(string, os.FileInfo, error) error { return nil }, WithTimeout(time.Minute)); err != nil {
+		t.Errorf("WalkRepo() with a generous timeout error = %v", err)
+	}
+}
+
+func TestWithRelativeTo(t *testing.T) {
+	tmpDir := t.TempDir()
+	root := filepath.Join(tmpDir, "work", "repo")
+	writeTree(t, root, map[string]string{
+		".gitignore": "*.log",
+		"a.txt":      "content",
+		"a.log":      "content",
+		"sub/b.txt":  "content",
+	})
+
+	tests := []struct {
+		name string
+		base string
+		want []string
+	}{
+		{"parent", filepath.Join(tmpDir, "work"), []string{"repo/a.txt", "repo/sub", "repo/sub/b.txt"}},
+		{"grandparent", tmpDir, []string{"work/repo/a.txt", "work/repo/sub", "work/repo/sub/b.txt"}},
+		{"root", root, []string{"a.txt", "sub", "sub/b.txt"}},
+		{"sibling", filepath.Join(tmpDir, "work", "other"), []string{"../repo/a.txt", "../repo/sub", "../repo/sub/b.txt"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			err := WalkRepo(root, func(path string, info os.FileInfo, err error) error {
+				got = append(got, filepath.ToSlash(path))
+				return err
+			}, WithRelativeTo(tt.base))
+			if err != nil {
+				t.Fatalf("WalkRepo() error = %v", err)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("walked %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("relative root", func(t *testing.T) {
+		chdir(t, filepath.Join(tmpDir, "work"))
+		var got []string
+		err := WalkRepo("repo", func(path string, info os.FileInfo, err error) error {
+			got = append(got, filepath.ToSlash(path))
+			return err
+		}, WithRelativeTo(tmpDir))
+		if err != nil {
+			t.Fatalf("WalkRepo() error = %v", err)
+		}
+		sort.Strings(got)
+		want := []string{"work/repo/a.txt", "work/repo/sub", "work/repo/sub/b.txt"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("walked %v, want %v", got, want)
+		}
+	})
+}
+
+func TestWithAbsolutePaths(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTree(t, tmpDir, map[string]string{
+		"repo/.gitignore": "*.log",
+		"repo/a.txt":      "content",
+		"repo/a.log":      "content",
+		"repo/sub/b.txt":  "content",
+	})
+	chdir(t, tmpDir)
+	absRoot, err := filepath.Abs("repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, root := range []string{"repo", "./repo/", absRoot} {
+		var got, exited []string
+		err := WalkRepo(root, func(path string, info os.FileInfo, err error) error {
+			got = append(got, path)
+			return err
+		}, WithAbsolutePaths(true), WithDirExitHook(func(dir string) {
+			exited = append(exited, dir)
+		}))
+		if err != nil {
+			t.Fatalf("WalkRepo(%q) error = %v", root, err)
+		}
+		sort.Strings(got)
+		want := []string{
+			filepath.Join(absRoot, "a.txt"),
+			filepath.Join(absRoot, "sub"),
+			filepath.Join(absRoot, "sub", "b.txt"),
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("WalkRepo(%q) walked %q, want %q", root, got, want)
+		}
+		if want := []string{filepath.Join(absRoot, "sub"), absRoot}; !reflect.DeepEqual(exited, want) {
+			t.Errorf("WalkRepo(%q) exited %q, want %q", root, exited, want)
+		}
+	}
+
+	// Without the option, paths are joined onto the root as given.
+	err = WalkRepo("repo", func(path string, info os.FileInfo, err error) error {
+		if filepath.IsAbs(path) {
+			t.Errorf("without WithAbsolutePaths, reported absolute path %q", path)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("WalkRepo() error = %v", err)
+	}
+}
+
+func TestHelpersUnderPathOptions(t *testing.T) {
+	parent := t.TempDir()
+	writeTree(t, parent, map[string]string{
+		"repo/.git/HEAD":      "ref: refs/heads/main",
+		"repo/.gitignore":     "*.log\n.git/\n",
+		"repo/a.txt":          "content",
+		"repo/b.log":          "content",
+		"repo/sub/c.txt":      "content",
+		"repo/sub/d.log":      "content",
+		"repo/sub/deep/e.txt": "content",
+	})
+	chdir(t, parent)
+
+	helpers := map[string]func(root string, opts ...Option) (any, error){
+		"ListFilesPartial": func(root string, opts ...Option) (any, error) {
+			return ListFilesPartial(root, opts...)
+		},
+		"FilesByTopDir": func(root string, opts ...Option) (any, error) {
+			return FilesByTopDir(root, opts...)
+		},
+		"CollectFiles": func(root string, opts ...Option) (any, error) {
+			files, truncated, err := CollectFiles(root, 2, opts...)
+			return fmt.Sprint(files, truncated), err
+		},
+		"TreeHash": func(root string, opts ...Option) (any, error) {
+			return TreeHash(root, opts...)
+		},
+		"WalkChangedSince": func(root string, opts ...Option) (any, error) {
+			return WalkChangedSince(root, nil, func(string, os.FileInfo) error { return nil }, opts...)
+		},
+		"Manifest": func(root string, opts ...Option) (any, error) {
+			return Manifest(root, opts...)
+		},
+		"GenerateAllowlist": func(root string, opts ...Option) (any, error) {
+			return GenerateAllowlist(root, opts...)
+		},
+		"Partition": func(root string, opts ...Option) (any, error) {
+			kept, ignored, err := Partition(root, opts...)
+			return [][]string{kept, ignored}, err
+		},
+		"WalkRepoPorcelain": func(root string, opts ...Option) (any, error) {
+			var buf bytes.Buffer
+			err := WalkRepoPorcelain(&buf, root, opts...)
+			return buf.String(), err
+		},
+		"WalkRepoFormatted": func(root string, opts ...Option) (any, error) {
+			var got []string
+			err := WalkRepoFormatted(root, func(formatted string) error {
+				got = append(got, formatted)
+				return nil
+			}, opts...)
+			return got, err
+		},
+	}
+	variants := []struct {
+		name string
+		root string
+		opts []Option
+	}{
+		{"absolute paths", "repo", []Option{WithAbsolutePaths(true)}},
+		{"relative to subdirectory", "repo", []Option{WithRelativeTo(filepath.Join("repo", "sub"))}},
+		{"relative to parent", filepath.Join(parent, "repo"), []Option{WithRelativeTo(parent)}},
+		{"discover root", filepath.Join("repo", "sub", "deep"), []Option{WithDiscoverRoot(true)}},
+	}
+	for name, helper := range helpers {
+		t.Run(name, func(t *testing.T) {
+			want, err := helper("repo")
+			if err != nil {
+				t.Fatalf("without options: error = %v", err)
+			}
+			for _, v := range variants {
+				got, err := helper(v.root, v.opts...)
+				if err != nil {
+					t.Errorf("%s: error = %v", v.name, err)
+				} else if !reflect.DeepEqual(got, want) {
+					t.Errorf("%s: got %v, want %v", v.name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestWithFollowInternalSymlinks(t *testing.T) {
+	tmpDir := t.TempDir()
+	root := filepath.Join(tmpDir, "repo")
+	writeTree(t, root, map[string]string{
+		".gitignore":    "*.log",
+		"real/a.txt":    "content",
+		"real/b.log":    "content",
+		"real/deep/c":   "content",
+		"elsewhere.txt": "content",
+	})
+	writeTree(t, tmpDir, map[string]string{"outside/x.txt": "content"})
+
+	links := map[string]string{
+		"inner":    "real",
+		"file.lnk": "real/a.txt",
+		"out":      filepath.Join(tmpDir, "outside"),
+		"loop":     ".",
+		"real/up":  "..",
+		"dangling": "missing",
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, filepath.Join(root, link)); err != nil {
+			t.Skipf("symlinks unsupported: %v", err)
+		}
+	}
+
+	infos := make(map[string]os.FileInfo)
+	err := WalkRepo(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		infos[filepath.ToSlash(rel)] = info
+		return nil
+	}, WithFollowInternalSymlinks(true))
+	if err != nil {
+		t.Fatalf("WalkRepo() error = %v", err)
+	}
+
+	walked := make(map[string]bool)
+	for path := range infos {
+		walked[path] = true
+	}
+	assertWalked(t, walked,
+		[]string{"inner", "inner/a.txt", "inner/deep/c", "file.lnk", "out", "loop", "real/up", "dangling"},
+		[]string{"inner/b.log", "out/x.txt", "loop/real", "real/up/real", "inner/up/real"},
+	)
+
+	// Followed links are reported as their targets; the rest as symlinks.
+	for path, wantLink := range map[string]bool{"inner": false, "file.lnk": false, "out": true, "loop": true, "real/up": true, "dangling": true} {
+		if info := infos[path]; info != nil && (info.Mode()&os.ModeSymlink != 0) != wantLink {
+			t.Errorf("%s reported with mode %v", path, info.Mode())
+		}
+	}
+	if info := infos["inner"]; info == nil || !info.IsDir() {
+		t.Errorf("followed directory link inner was not reported as a directory")
+	}
+
+	// Without the option, no links are followed.
+	walked = walkedPaths(t, root)
+	assertWalked(t, walked, []string{"inner", "file.lnk"}, []string{"inner/a.txt"})
+}
+
+func TestWithDirExitHook(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":      "ignored/\n",
+		"a/b/c.txt":       "content",
+		"a/d.txt":         "content",
+		"e/f.txt":         "content",
+		"ignored/g.txt":   "content",
+		"skipped/h.txt":   "content",
+		"skipped/i/j.txt": "content",
+	})
+
+	var events []string
+	err := WalkRepo(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		if info.IsDir() && info.Name() == "skipped" {
+			return filepath.SkipDir
+		}
+		events = append(events, "visit "+filepath.ToSlash(rel))
+		return nil
+	}, WithDirExitHook(func(dir string) {
+		rel, _ := filepath.Rel(root, dir)
+		events = append(events, "exit "+filepath.ToSlash(rel))
+	}))
+	if err != nil {
+		t.Fatalf("WalkRepo() error = %v", err)
+	}
+
+	index := make(map[string]int)
+	for i, event := range events {
+		index[event] = i
+	}
+	exits := []string{"a/b", "a", "e", "."}
+	for _, dir := range exits {
+		if _, ok := index["exit "+dir]; !ok {
+			t.Fatalf("no exit event for %s in %q", dir, events)
+		}
+	}
+	for _, dir := range []string{"ignored", "skipped", "skipped/i"} {
+		if _, ok := index["exit "+dir]; ok {
+			t.Errorf("unexpected exit event for %s", dir)
+		}
+	}
+	if last := events[len(events)-1]; last != "exit ." {
+		t.Errorf("last event = %q, want the root's exit", last)
+	}
+
+	// Each directory exits after its own visit and after every visit and exit
+	// of its descendants.
+	for i, event := range events {
+		path := strings.SplitN(event, " ", 2)[1]
+		for _, dir := range exits {
+			if dir == path || (dir != "." && !strings.HasPrefix(path, dir+"/")) {
+				continue
+			}
+			if i > index["exit "+dir] {
+				t.Errorf("%q came after exit %s", event, dir)
+			}
+		}
+	}
+}
+
+func TestWithEmptyDirCallback(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":         "*.log\n",
+		"a.txt":              "content",
+		"logs/a.log":         "content",
+		"logs/b.log":         "content",
+		"nested/only/x.log":  "content",
+		"rules/.gitignore":   "*\n",
+		"rules/hidden.txt":   "content",
+		"kept/file.txt":      "content",
+		"kept/skipped/y.txt": "content",
+	})
+	if err := os.Mkdir(filepath.Join(root, "empty"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	collect := func(opts ...Option) []string {
+		var empty []string
+		opts = append(opts, WithEmptyDirCallback(func(dir string) {
+			rel, _ := filepath.Rel(root, dir)
+			empty = append(empty, filepath.ToSlash(rel))
+		}))
+		err := WalkRepo(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.Name() == "skipped" {
+				return filepath.SkipDir
+			}
+			return nil
+		}, opts...)
+		if err != nil {
+			t.Fatalf("WalkRepo() error = %v", err)
+		}
+		sort.Strings(empty)
+		return empty
+	}
+
+	// nested holds only a directory, so isn't empty itself.
+	if got, want := collect(), []string{"empty", "logs", "nested/only", "rules"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("empty directories = %q, want %q", got, want)
+	}
+	if got, want := collect(WithReportIgnoreFiles(true)), []string{"empty", "logs", "nested/only"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("with ignore files reported, empty directories = %q, want %q", got, want)
+	}
+	if got, want := collect(WithTraversal(BreadthFirst)), []string{"empty", "logs", "nested/only", "rules"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("breadth first, empty directories = %q, want %q", got, want)
+	}
+}
+
+func TestWithDescendFilter(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":           "*.log\n",
+		"a.txt":                "content",
+		"one/b.txt":            "content",
+		"one/two/c.txt":        "content",
+		"one/two/c.log":        "content",
+		"one/two/three/d.txt":  "content",
+		"other/two/e.txt":      "content",
+		"other/two/three/f.md": "content",
+	})
+
+	// Limit the walk to two levels of directories, by depth below the root.
+	depth := func(dir string) int {
+		rel, _ := filepath.Rel(root, dir)
+		return strings.Count(filepath.ToSlash(rel), "/") + 1
+	}
+	var filtered []string
+	walked := walkedPaths(t, root, WithDescendFilter(func(dir string, info os.FileInfo) bool {
+		if !info.IsDir() {
+			t.Errorf("filter given non-directory %s", dir)
+		}
+		rel, _ := filepath.Rel(root, dir)
+		filtered = append(filtered, filepath.ToSlash(rel))
+		return depth(dir) < 2
+	}))
+	assertWalked(t, walked,
+		[]string{"a.txt", "one", "one/b.txt", "one/two", "other", "other/two"},
+		[]string{"one/two/c.txt", "one/two/c.log", "one/two/three", "other/two/e.txt"},
+	)
+	sort.Strings(filtered)
+	if want := []string{"one", "one/two", "other", "other/two"}; !reflect.DeepEqual(filtered, want) {
+		t.Errorf("filter consulted for %q, want %q", filtered, want)
+	}
+
+	// Subtree walks make their way down as the walk would.
+	var sub []string
+	err := WalkSubtree(root, filepath.Join("one", "two"), func(path string, info os.FileInfo, err error) error {
+		sub = append(sub, path)
+		return err
+	}, WithDescendFilter(func(dir string, info os.FileInfo) bool { return filepath.Base(dir) != "two" }))
+	if err != nil || len(sub) != 0 {
+		t.Errorf("WalkSubtree() beneath a filtered directory walked %q, error %v", sub, err)
+	}
+}
+
+func TestWithSimpleExcludes(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":       "*.log\n!keep.log\n!*.tmp\n",
+		"a.tmp":            "content",
+		"sub/b.tmp":        "content",
+		"sub/c.txt":        "content",
+		"sub/debug.log":    "content",
+		"sub/keep.log":     "content",
+		"cache/d.txt":      "content",
+		"deep/cache/e.txt": "content",
+	})
+
+	walked := walkedPaths(t, root, WithSimpleExcludes("*.tmp", "cache"))
+	assertWalked(t, walked,
+		[]string{"sub", "sub/c.txt", "sub/keep.log", "deep"},
+		[]string{"a.tmp", "sub/b.tmp", "sub/debug.log", "cache", "cache/d.txt", "deep/cache", "deep/cache/e.txt"},
+	)
+}
+
+func TestWithExcludeRegexp(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":              "*.log\n!tmp-*\n",
+		"test_7.py":               "content",
+		"test_1234.py":            "content",
+		"pkg/test_0042.py":        "content",
+		"pkg/test_12a.py":         "content",
+		"pkg/debug.log":           "content",
+		"tmp-0badf00d/a.txt":      "content",
+		"tmp-notahash/b.txt":      "content",
+		"docs/v2/index.md":        "content",
+		"docs/v2beta/index.md":    "content",
+		"docs/latest/v2/index.md": "content",
+	})
+
+	// Test files numbered with three or more digits, directories named by a
+	// hexadecimal hash, and versioned docs at the top of docs only.
+	walked := walkedPaths(t, root, WithExcludeRegexp(
+		regexp.MustCompile(`(^|/)test_[0-9]{3,}\.py$`),
+		regexp.MustCompile(`(^|/)tmp-[0-9a-f]{8}$`),
+		regexp.MustCompile(`^docs/v[0-9]+$`),
+	))
+	assertWalked(t, walked,
+		[]string{"test_7.py", "pkg/test_12a.py", "tmp-notahash", "tmp-notahash/b.txt", "docs/v2beta/index.md", "docs/latest/v2/index.md"},
+		[]string{"test_1234.py", "pkg/test_0042.py", "pkg/debug.log", "tmp-0badf00d", "tmp-0badf00d/a.txt", "docs/v2", "docs/v2/index.md"},
+	)
+}
+
+func TestWithRetries(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"flaky/a.txt": "content",
+		"b.txt":       "content",
+	})
+	flaky := filepath.Join(root, "flaky")
+
+	// failing makes the listing of flaky fail with err the given number of
+	// times, counting every attempt.
+	var attempts int
+	failing := func(t *testing.T, failures int, err error) {
+		attempts = 0
+		orig := readDir
+		readDir = func(path string) ([]fs.DirEntry, error) {
+			if path != flaky {
+				return orig(path)
+			}
+			attempts++
+			if attempts <= failures {
+				return nil, &fs.PathError{Op: "readdirent", Path: path, Err: err}
+			}
+			return orig(path)
+		}
+		t.Cleanup(func() { readDir = orig })
+	}
+	walk := func(opts ...Option) (map[string]bool, error) {
+		walked := make(map[string]bool)
+		err := WalkRepo(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, _ := filepath.Rel(root, path)
+			walked[filepath.ToSlash(rel)] = true
+			return nil
+		}, opts...)
+		return walked, err
+	}
+
+	t.Run("transient error retried", func(t *testing.T) {
+		failing(t, 2, syscall.EAGAIN)
+		walked, err := walk(WithRetries(2, time.Millisecond))
+		if err != nil {
+			t.Fatalf("WalkRepo() error = %v", err)
+		}
+		if !walked["flaky/a.txt"] || attempts != 3 {
+			t.Errorf("walked %v in %d attempts, want flaky/a.txt in 3", walked, attempts)
+		}
+	})
+
+	t.Run("retries exhausted", func(t *testing.T) {
+		failing(t, 3, syscall.EINTR)
+		if _, err := walk(WithRetries(2, time.Millisecond)); !errors.Is(err, syscall.EINTR) {
+			t.Errorf("WalkRepo() error = %v, want EINTR", err)
+		}
+		if attempts != 3 {
+			t.Errorf("%d attempts, want 3", attempts)
+		}
+	})
+
+	t.Run("without retries", func(t *testing.T) {
+		failing(t, 1, syscall.EAGAIN)
+		if _, err := walk(); !errors.Is(err, syscall.EAGAIN) {
+			t.Errorf("WalkRepo() error = %v, want EAGAIN", err)
+		}
+	})
+
+	t.Run("permanent error not retried", func(t *testing.T) {
+		failing(t, 1, syscall.EACCES)
+		if _, err := walk(WithRetries(5, time.Millisecond)); !errors.Is(err, syscall.EACCES) {
+			t.Errorf("WalkRepo() error = %v, want EACCES", err)
+		}
+		if attempts != 1 {
+			t.Errorf("%d attempts, want 1", attempts)
+		}
+	})
+}
+
+func TestWithTraversalBreadthFirst(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":        "*.log\n",
+		"top.txt":           "content",
+		"a/.gitignore":      "*.tmp\n",
+		"a/one.txt":         "content",
+		"a/one.tmp":         "content",
+		"a/b/two.txt":       "content",
+		"a/b/c/three.txt":   "content",
+		"a/b/c/three.log":   "content",
+		"d/one.txt":         "content",
+		"d/one.tmp":         "content",
+		"d/e/two.txt":       "content",
+		"d/e/f/g/four.txt":  "content",
+		"skipped/h/two.txt": "content",
+		"skipped/h/i/three": "content",
+	})
+
+	var order []string
+	err := WalkRepo(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Name() == "skipped" {
+			return filepath.SkipDir
+		}
+		rel, _ := filepath.Rel(root, path)
+		order = append(order, filepath.ToSlash(rel))
+		return nil
+	}, WithTraversal(BreadthFirst))
+	if err != nil {
+		t.Fatalf("WalkRepo() error = %v", err)
+	}
+
+	walked := make(map[string]bool)
+	for _, path := range order {
+		walked[path] = true
+	}
+	// The per-directory rules apply as in a depth-first walk.
+	assertWalked(t, walked,
+		[]string{"top.txt", "a/one.txt", "a/b/c/three.txt", "d/one.tmp", "d/e/f/g/four.txt"},
+		[]string{"a/one.tmp", "a/b/c/three.log", "skipped/h", "skipped/h/two.txt"},
+	)
+	if len(order) != 15 {
+		t.Errorf("visited %d entries, want 15: %q", len(order), order)
+	}
+
+	// Each entry is at least as deep as the one before it, and the children
+	// of each level appear in the order their parents did.
+	index := make(map[string]int)
+	for i, path := range order {
+		index[path] = i
+	}
+	depth := func(path string) int { return strings.Count(path, "/") }
+	for i := 1; i < len(order); i++ {
+		prev, cur := order[i-1], order[i]
+		if depth(cur) < depth(prev) {
+			t.Errorf("%q (depth %d) visited after %q (depth %d)", cur, depth(cur), prev, depth(prev))
+		}
+		if depth(cur) == depth(prev) && depth(cur) > 0 {
+			if index[path.Dir(cur)] < index[path.Dir(prev)] {
+				t.Errorf("%q visited after %q, though its parent was visited first", cur, prev)
+			}
+		}
+	}
+}
+
+func TestWithDirSort(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore": "*.log\n",
+		"c.txt":      "content",
+		"a/z.txt":    "content",
+		"a/m/y.txt":  "content",
+		"a/b.txt":    "content",
+		"b.log":      "content",
+		"b/x.txt":    "content",
+	})
+
+	// List every directory in reverse, so that any sorting is the walk's.
+	orig := readDir
+	readDir = func(path string) ([]fs.DirEntry, error) {
+		files, err := orig(path)
+		sort.Slice(files, func(i, j int) bool { return files[i].Name() > files[j].Name() })
+		return files, err
+	}
+	t.Cleanup(func() { readDir = orig })
+
+	walk := func(opts ...Option) []string {
+		var order []string
+		err := WalkRepo(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, _ := filepath.Rel(root, path)
+			order = append(order, filepath.ToSlash(rel))
+			return nil
+		}, opts...)
+		if err != nil {
+			t.Fatalf("WalkRepo() error = %v", err)
+		}
+		return order
+	}
+
+	unsorted := walk()
+	want := []string{"c.txt", "b", "b/x.txt", "a", "a/z.txt", "a/m", "a/m/y.txt", "a/b.txt"}
+	if !reflect.DeepEqual(unsorted, want) {
+		t.Errorf("without WithDirSort, walked %q, want %q", unsorted, want)
+	}
+
+	// Siblings are sorted, and each directory's contents still follow it.
+	sorted := walk(WithDirSort(true))
+	want = []string{"a", "a/b.txt", "a/m", "a/m/y.txt", "a/z.txt", "b", "b/x.txt", "c.txt"}
+	if !reflect.DeepEqual(sorted, want) {
+		t.Errorf("with WithDirSort, walked %q, want %q", sorted, want)
+	}
+
+	sorted = walk(WithDirSort(true), WithTraversal(BreadthFirst))
+	want = []string{"a", "b", "c.txt", "a/b.txt", "a/m", "a/z.txt", "b/x.txt", "a/m/y.txt"}
+	if !reflect.DeepEqual(sorted, want) {
+		t.Errorf("with WithDirSort breadth first, walked %q, want %q", sorted, want)
+	}
+}
+
+func TestWithLogger(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":   "*.log\n!keep.log\n",
+		"a.log":        "content",
+		"keep.log":     "content",
+		"a.txt":        "content",
+		"sub/b.txt":    "content",
+		"broken/c.txt": "content",
+	})
+	injectReadDirError(t, filepath.Join(root, "broken"), errors.New("unreadable"))
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	err := WalkRepo(root, func(path string, info os.FileInfo, err error) error {
+		return err
+	}, WithLogger(logger), WithErrorHandler(func(path string, err error) error { return nil }))
+	if err != nil {
+		t.Fatalf("WalkRepo() error = %v", err)
+	}
+
+	type record struct {
+		Level   string
+		Msg     string
+		Path    string
+		Ignored *bool
+		Pattern *string
+		Error   string
+		Skipped *bool
+	}
+	records := make(map[string]record)
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var r record
+		if err := dec.Decode(&r); err != nil {
+			t.Fatal(err)
+		}
+		if r.Level != "DEBUG" {
+			t.Errorf("record %+v logged at %s, want DEBUG", r, r.Level)
+		}
+		rel, _ := filepath.Rel(root, r.Path)
+		records[r.Msg+" "+filepath.ToSlash(rel)] = r
+	}
+
+	for _, dir := range []string{".", "sub", "broken"} {
+		if _, ok := records["walkrepo: entering directory "+dir]; !ok {
+			t.Errorf("no record of entering %s", dir)
+		}
+	}
+	for path, want := range map[string]struct {
+		ignored bool
+		pattern string
+	}{
+		"a.log":    {true, "*.log"},
+		"keep.log": {false, "!keep.log"},
+		"a.txt":    {false, ""},
+		"sub":      {false, ""},
+	} {
+		r, ok := records["walkrepo: ignore decision "+path]
+		if !ok {
+			t.Errorf("no ignore decision recorded for %s", path)
+			continue
+		}
+		if r.Ignored == nil || *r.Ignored != want.ignored || r.Pattern == nil || *r.Pattern != want.pattern {
+			t.Errorf("decision for %s = %+v, want ignored %v by %q", path, r, want.ignored, want.pattern)
+		}
+	}
+	if r, ok := records["walkrepo: error broken"]; !ok || r.Error != "unreadable" || r.Skipped == nil || !*r.Skipped {
+		t.Errorf("error record for broken = %+v, want a skipped %q", r, "unreadable")
+	}
+}
+
+func TestWithSnapshotIgnores(t *testing.T) {
+	for _, snapshot := range []bool{false, true} {
+		t.Run(fmt.Sprintf("snapshot=%v", snapshot), func(t *testing.T) {
+			root := t.TempDir()
+			writeTree(t, root, map[string]string{
+				".gitignore":   "*.log\n",
+				"a.log":        "content",
+				"b/.gitignore": "*.tmp\n",
+				"b/x.txt":      "content",
+				"b/y.tmp":      "content",
+			})
+
+			// Rewrite b's ignore file as the walk reaches b, before it is
+			// descended into.
+			walked := make(map[string]bool)
+			err := WalkRepo(root, func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				rel, _ := filepath.Rel(root, path)
+				walked[filepath.ToSlash(rel)] = true
+				if rel == "b" {
+					writeTree(t, root, map[string]string{"b/.gitignore": "*.txt\n!*.tmp\n"})
+				}
+				return nil
+			}, WithSnapshotIgnores(snapshot))
+			if err != nil {
+				t.Fatalf("WalkRepo() error = %v", err)
+			}
+
+			if snapshot {
+				assertWalked(t, walked, []string{"b/x.txt"}, []string{"a.log", "b/y.tmp"})
+			} else {
+				assertWalked(t, walked, []string{"b/y.tmp"}, []string{"a.log", "b/x.txt"})
+			}
+		})
+	}
+}
+
+func TestWithDomainPrefix(t *testing.T) {
+	outside := t.TempDir()
+	writeTree(t, outside, map[string]string{
+		"mounts.gitignore": "/vendor/lib/\n/lib/\n/vendor/docs/*.md\n!/vendor/docs/keep.md\n",
+	})
+	base := WithBaseIgnoreFiles(filepath.Join(outside, "mounts.gitignore"))
+
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":      "/build/\n",
+		".gitattributes":  "[attr]drop export-ignore\n*.tmp drop\n",
+		"lib/a.go":        "content",
+		"build/out":       "content",
+		"docs/a.md":       "content",
+		"docs/keep.md":    "content",
+		"sub/.gitignore":  "/gen/\n",
+		"sub/gen/g.go":    "content",
+		"sub/lib/b.go":    "content",
+		"sub/build/c.go":  "content",
+		"scratch.tmp":     "content",
+		"vendor/lib/d.go": "content",
+	})
+
+	// The mounted patterns anchor at the logical root above the walked
+	// root; the tree's own patterns and attributes at their directories.
+	got := walkedPaths(t, root, base, WithDomainPrefix([]string{"vendor"}), WithExportIgnore(true))
+	assertWalked(t, got,
+		[]string{"docs", "docs/keep.md", "sub", "sub/lib", "sub/lib/b.go", "sub/build/c.go", "vendor/lib/d.go"},
+		[]string{"lib", "lib/a.go", "build", "docs/a.md", "sub/gen", "scratch.tmp"})
+
+	// CheckIgnore agrees with the walk.
+	ignored := map[string]bool{
+		"lib/a.go":        true,
+		"build/out":       true,
+		"docs/a.md":       true,
+		"docs/keep.md":    false,
+		"sub/gen/g.go":    true,
+		"sub/lib/b.go":    false,
+		"sub/build/c.go":  false,
+		"vendor/lib/d.go": false,
+	}
+	paths := make([]string, 0, len(ignored))
+	for path := range ignored {
+		paths = append(paths, filepath.FromSlash(path))
+	}
+	results, err := CheckIgnore(root, paths, base, WithDomainPrefix([]string{"vendor"}))
+	if err != nil {
+		t.Fatalf("CheckIgnore() error = %v", err)
+	}
+	for _, result := range results {
+		if want := ignored[filepath.ToSlash(result.Path)]; result.Ignored != want {
+			t.Errorf("CheckIgnore(%q).Ignored = %v, want %v", result.Path, result.Ignored, want)
+		}
+	}
+
+	// Without the prefix, the mounted patterns anchor at the walked root.
+	got = walkedPaths(t, root, base, WithExportIgnore(true))
+	assertWalked(t, got,
+		[]string{"docs/a.md", "docs/keep.md", "sub/lib/b.go", "vendor"},
+		[]string{"lib", "build", "sub/gen", "scratch.tmp", "vendor/lib"})
+}
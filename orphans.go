@@ -0,0 +1,86 @@
+package walkrepo
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+)
+
+// OrphanedIgnores reports the patterns of the ignore files beneath root which
+// match nothing currently in the tree, and so are likely stale. Each is given
+// as "source:line: pattern", with the ignore file's path relative to root in
+// slash form, ordered by path and then line.
+//
+// Every entry of the tree counts, ignored or not, including those within
+// ignored directories, so a pattern is only reported if removing it could
+// not change what is ignored today. A negation counts as matching whatever it
+// would re-include. With WithIgnoreCase, patterns match without regard to
+// case, as in the walk. The .git directory is never examined.
+func OrphanedIgnores(root string, opts ...Option) ([]string, error) {
+	root = filepath.Clean(filepath.FromSlash(root))
+	cfg := newConfig(opts)
+
+	var all []*orphanCandidate
+	var visit func(dir string, domain []string, inScope []*orphanCandidate) error
+	visit = func(dir string, domain []string, inScope []*orphanCandidate) error {
+		files, err := cfg.listDir(dir)
+		if err != nil {
+			return err
+		}
+		sps, err := cfg.ignorePatternsIn(dir, domain, files, nil)
+		if err != nil {
+			return err
+		}
+		inScope = inScope[:len(inScope):len(inScope)]
+		for _, sp := range sps {
+			c := &orphanCandidate{pattern: sp}
+			all = append(all, c)
+			inScope = append(inScope, c)
+		}
+
+		for _, file := range files {
+			if file.Name() == ".git" && file.IsDir() {
+				continue
+			}
+			path := append(domain[:len(domain):len(domain)], file.Name())
+			for _, c := range inScope {
+				if !c.matched && matchPattern(c.pattern, path, file.IsDir(), cfg.ignoreCase) != gitignore.NoMatch {
+					c.matched = true
+				}
+			}
+			if file.IsDir() {
+				if err := visit(filepath.Join(dir, file.Name()), path, inScope); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if err := visit(root, []string{}, nil); err != nil {
+		return nil, err
+	}
+
+	// Ignore files are read in the order of their directories' listings;
+	// report them in path order.
+	sort.SliceStable(all, func(i, j int) bool { return all[i].pattern.Source < all[j].pattern.Source })
+	var orphans []string
+	for _, c := range all {
+		if c.matched {
+			continue
+		}
+		source := c.pattern.Source
+		if rel, err := filepath.Rel(root, source); err == nil {
+			source = filepath.ToSlash(rel)
+		}
+		orphans = append(orphans, fmt.Sprintf("%s:%d: %s", source, c.pattern.Line, c.pattern.Text))
+	}
+	return orphans, nil
+}
+
+// orphanCandidate tracks whether an ignore pattern has matched any entry.
+type orphanCandidate struct {
+	pattern SourcedPattern
+	matched bool
+}
@@ -0,0 +1,80 @@
+package walkrepo
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOrphanedIgnores(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":       "# build output\n*.log\nbuild/\n*.pyc\n!important.log\n/dist\n",
+		"sub/.gitignore":   "*.tmp\ncache/\n*.log\n",
+		"other/.gitignore": "*.bak\n",
+		"a.log":            "content",
+		"build/out/x.o":    "content",
+		"sub/deep/a.tmp":   "content",
+		"sub/b.txt":        "content",
+		"other/c.txt":      "content",
+		"dist.txt":         "content",
+		".git/x.pyc":       "content",
+	})
+
+	got, err := OrphanedIgnores(root)
+	if err != nil {
+		t.Fatalf("OrphanedIgnores() error = %v", err)
+	}
+	want := []string{
+		".gitignore:4: *.pyc",          // only within .git
+		".gitignore:5: !important.log", // nothing to re-include
+		".gitignore:6: /dist",          // dist.txt isn't dist
+		"other/.gitignore:1: *.bak",
+		"sub/.gitignore:2: cache/",
+		"sub/.gitignore:3: *.log", // a.log lies outside sub
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("OrphanedIgnores() = %q, want %q", got, want)
+	}
+
+	// Entries within ignored directories keep their patterns alive.
+	writeTree(t, root, map[string]string{
+		"build/x.pyc":       "content",
+		"sub/cache/x.bin":   "content",
+		"other/old.bak":     "content",
+		"dist/index.html":   "content",
+		"important.log":     "content",
+		"sub/deep/more.log": "content",
+	})
+	got, err = OrphanedIgnores(root)
+	if err != nil {
+		t.Fatalf("OrphanedIgnores() error = %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("OrphanedIgnores() = %q, want none", got)
+	}
+}
+
+func TestOrphanedIgnoresIgnoreCase(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore": "readme\n*.TMP\n",
+		"README":     "content",
+		"a.tmp":      "content",
+	})
+
+	got, err := OrphanedIgnores(root, WithIgnoreCase(true))
+	if err != nil {
+		t.Fatalf("OrphanedIgnores() error = %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("OrphanedIgnores() with WithIgnoreCase = %q, want none", got)
+	}
+
+	got, err = OrphanedIgnores(root)
+	if err != nil {
+		t.Fatalf("OrphanedIgnores() error = %v", err)
+	}
+	if want := []string{".gitignore:1: readme", ".gitignore:2: *.TMP"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("OrphanedIgnores() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,67 @@
+package walkrepo
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Partition walks root, accounting for every file beneath it as either kept,
+// being passed to the walk function, or ignored by the rules. The contents of
+// ignored directories are listed as ignored too. Paths are relative to root
+// and sorted. With WithPartitionDirs, directories are listed as well.
+//
+// Entries which the walk passes over without applying the rules, such as the
+// ignore files themselves (unless reported with WithReportIgnoreFiles) and
+// .git directories skipped with WithSkipGit, appear in neither list.
+func Partition(root string, opts ...Option) (kept, ignored []string, err error) {
+	root, opts, err = rootRelative(root, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg := newConfig(opts)
+	add := func(list *[]string, path string, isDir bool) error {
+		if isDir && !cfg.partitionDirs {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		*list = append(*list, rel)
+		return nil
+	}
+
+	addIgnored := func(path string, d fs.DirEntry) error {
+		if !d.IsDir() {
+			return add(&ignored, path, false)
+		}
+		return filepath.WalkDir(path, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if cfg.skipGit && d.Name() == ".git" {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			return add(&ignored, path, d.IsDir())
+		})
+	}
+
+	opts = append(opts[:len(opts):len(opts)], withIgnoredHook(addIgnored))
+	err = WalkRepo(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		return add(&kept, path, info.IsDir())
+	}, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Strings(kept)
+	sort.Strings(ignored)
+	return kept, ignored, nil
+}
@@ -0,0 +1,62 @@
+package walkrepo
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPartition(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":          "*.log\nbuild/\n",
+		"main.go":             "content",
+		"debug.log":           "content",
+		"build/out.bin":       "content",
+		"build/sub/more.bin":  "content",
+		"src/.gitignore":      "gen.go\n",
+		"src/lib.go":          "content",
+		"src/gen.go":          "content",
+		"src/deep/trace.log":  "content",
+		"src/deep/keep.txt":   "content",
+		".git/HEAD":           "ref: refs/heads/main",
+		".git/objects/a/b/cd": "content",
+	})
+
+	slashes := func(paths []string) []string {
+		for i, path := range paths {
+			paths[i] = filepath.ToSlash(path)
+		}
+		return paths
+	}
+
+	t.Run("files", func(t *testing.T) {
+		kept, ignored, err := Partition(root, WithSkipGit(true))
+		if err != nil {
+			t.Fatalf("Partition() error = %v", err)
+		}
+		wantKept := []string{"main.go", "src/deep/keep.txt", "src/lib.go"}
+		wantIgnored := []string{"build/out.bin", "build/sub/more.bin", "debug.log", "src/deep/trace.log", "src/gen.go"}
+		if got := slashes(kept); !reflect.DeepEqual(got, wantKept) {
+			t.Errorf("kept = %q, want %q", got, wantKept)
+		}
+		if got := slashes(ignored); !reflect.DeepEqual(got, wantIgnored) {
+			t.Errorf("ignored = %q, want %q", got, wantIgnored)
+		}
+	})
+
+	t.Run("with directories", func(t *testing.T) {
+		kept, ignored, err := Partition(root, WithSkipGit(true), WithPartitionDirs(true))
+		if err != nil {
+			t.Fatalf("Partition() error = %v", err)
+		}
+		wantKept := []string{"main.go", "src", "src/deep", "src/deep/keep.txt", "src/lib.go"}
+		wantIgnored := []string{"build", "build/out.bin", "build/sub", "build/sub/more.bin", "debug.log", "src/deep/trace.log", "src/gen.go"}
+		if got := slashes(kept); !reflect.DeepEqual(got, wantKept) {
+			t.Errorf("kept = %q, want %q", got, wantKept)
+		}
+		if got := slashes(ignored); !reflect.DeepEqual(got, wantIgnored) {
+			t.Errorf("ignored = %q, want %q", got, wantIgnored)
+		}
+	})
+}
@@ -0,0 +1,366 @@
+package walkrepo
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+)
+
+// ReadPatterns reads the ignore file at path, returning its patterns
+// rooted at domain (the components of the file's directory, relative to the
+// repository root) along with the line each came from.
+func ReadPatterns(path string, domain []string) ([]SourcedPattern, error) {
+	return parseFilePatterns(path, domain)
+}
+
+// EvaluatePattern reports whether the single .gitignore line pattern, found
+// in the repository's root, excludes path. The path is slash-separated and
+// relative to the root; isDir reports whether it names a directory. Comments,
+// blank lines, negations and patterns spanning several lines never exclude.
+func EvaluatePattern(pattern, path string, isDir bool) bool {
+	if strings.Contains(pattern, "\n") {
+		return false
+	}
+	sps := parsePatterns(pattern, "", nil)
+	if len(sps) != 1 {
+		return false
+	}
+
+	var components []string
+	for _, c := range strings.Split(path, "/") {
+		if c != "" {
+			components = append(components, c)
+		}
+	}
+	// As in git, a path is also excluded when any of its ancestors is.
+	for i := range components {
+		last := i == len(components)-1
+		if sps[0].Match(components[:i+1], isDir || !last) == gitignore.Exclude {
+			return true
+		}
+	}
+	return false
+}
+
+// parseFilePatterns parses the ignore file at path and returns its patterns,
+// recording the file and line each pattern came from.
+func parseFilePatterns(path string, domain []string) ([]SourcedPattern, error) {
+	fileBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return parsePatterns(string(fileBytes), path, domain), nil
+}
+
+// SourcedPattern is a parsed gitignore.Pattern along with where it came from:
+// its text, the file it was read from and its 1-based line number there.
+type SourcedPattern struct {
+	gitignore.Pattern
+	Text   string
+	Source string
+	Line   int
+}
+
+// parsePatterns parses newline-separated .gitignore content read from source
+// into a list of patterns rooted at domain.
+func parsePatterns(content, source string, domain []string) []SourcedPattern {
+	filePatterns := []SourcedPattern{}
+
+	// Split the contents of the .gitignore file into rawPatterns. As in git,
+	// a final line needs no newline, and lines may end in CRLF.
+	rawPatterns := strings.Split(content, "\n")
+	for i, rawPattern := range rawPatterns {
+		rawPattern = strings.TrimSuffix(rawPattern, "\r")
+		// Ignore empty lines and comments. As in git, only a "#" at the very
+		// start of a line begins a comment: after leading whitespace it is
+		// part of a pattern, and "\#" escapes a pattern's leading "#".
+		if rawPattern == "" || strings.HasPrefix(rawPattern, "#") {
+			continue
+		}
+		pattern := parsePattern(rawPattern, domain)
+
+		filePatterns = append(filePatterns, SourcedPattern{
+			Pattern: pattern,
+			Text:    rawPattern,
+			Source:  source,
+			Line:    i + 1,
+		})
+	}
+	return filePatterns
+}
+
+// pattern is a single gitignore pattern. Unlike those of go-git's
+// gitignore.ParsePattern, it matches as git does: against the whole of a path
+// relative to its domain, and not also against the path's ancestors. So
+// `!src/` re-includes the directory src but not its contents, and `src/**`
+// matches the contents of src but not src itself. Paths within an excluded
+// directory are accounted for by never descending into it.
+type pattern struct {
+	domain    []string
+	pattern   []string
+	inclusion bool
+	dirOnly   bool
+	anchored  bool
+	// outer holds the components of the walk's root beneath the directory
+	// the pattern is rooted at, when that lies above the walk's root, as
+	// the repository root does for .git/info/exclude in a walk of a
+	// subdirectory. They are prepended to the paths matched.
+	outer []string
+}
+
+// parsePattern parses a single .gitignore line into a pattern rooted at
+// domain.
+func parsePattern(p string, domain []string) *pattern {
+	// storing domain, copy it to ensure it isn't changed externally
+	res := &pattern{domain: append([]string(nil), domain...)}
+
+	if strings.HasPrefix(p, "!") {
+		res.inclusion = true
+		p = p[1:]
+	}
+
+	if !strings.HasSuffix(p, "\\ ") {
+		p = strings.TrimRight(p, " ")
+	}
+
+	if strings.HasSuffix(p, "/") {
+		res.dirOnly = true
+		p = p[:len(p)-1]
+	}
+
+	// A slash anywhere but the end anchors the pattern to its domain;
+	// otherwise it matches a name at any depth. Git leaves "." components
+	// as they are, so that "./build" anchors but (no path having a "."
+	// component) matches nothing; so do we.
+	if strings.Contains(p, "/") {
+		res.anchored = true
+		p = strings.TrimPrefix(p, "/")
+	}
+
+	res.pattern = strings.Split(p, "/")
+	for i, glob := range res.pattern {
+		res.pattern[i] = translateGlob(glob)
+	}
+	return res
+}
+
+// Match implements gitignore.Pattern.
+func (p *pattern) Match(path []string, isDir bool) gitignore.MatchResult {
+	return p.match(path, isDir, false)
+}
+
+// match matches path as Match does, without regard to case if foldCase is
+// set.
+func (p *pattern) match(path []string, isDir, foldCase bool) gitignore.MatchResult {
+	if len(p.outer) > 0 {
+		path = append(p.outer[:len(p.outer):len(p.outer)], path...)
+	}
+	if len(path) <= len(p.domain) {
+		return gitignore.NoMatch
+	}
+	for i, e := range p.domain {
+		if path[i] != e && !(foldCase && strings.EqualFold(path[i], e)) {
+			return gitignore.NoMatch
+		}
+	}
+	path = path[len(p.domain):]
+
+	if p.dirOnly && !isDir {
+		return gitignore.NoMatch
+	}
+	if p.anchored && !matchComponents(p.pattern, path, foldCase) {
+		return gitignore.NoMatch
+	} else if !p.anchored && !matchName(p.pattern[0], path[len(path)-1], foldCase) {
+		return gitignore.NoMatch
+	}
+
+	if p.inclusion {
+		return gitignore.Include
+	}
+	return gitignore.Exclude
+}
+
+// matchPattern matches path against p as p.Match does, but without regard to
+// case if foldCase is set. Only the package's own patterns can fold case;
+// others, such as go-git's given to WalkWithBasePatterns, match as they
+// would.
+func matchPattern(p gitignore.Pattern, path []string, isDir, foldCase bool) gitignore.MatchResult {
+	if sp, ok := p.(SourcedPattern); ok {
+		p = sp.Pattern
+	}
+	if own, ok := p.(*pattern); ok && foldCase {
+		return own.match(path, isDir, true)
+	}
+	return p.Match(path, isDir)
+}
+
+// matchComponents reports whether the slash-separated components of an
+// anchored pattern match the whole of path, without regard to case if
+// foldCase is set. A "**" component matches zero or more directories, except
+// when trailing, where it matches everything inside a directory (but not the
+// directory itself).
+//
+// As in matching "*" in a string, only the last "**" seen is ever retried on
+// a mismatch, with one more component: the components before it matched as
+// early as they could, leaving the most for the rest. So matching takes time
+// proportional to the product of the lengths, however many "**" there are.
+func matchComponents(pattern, path []string, foldCase bool) bool {
+	p, c := 0, 0
+	star, starC := -1, 0 // the last "**" seen, and where its match ends
+	for c < len(path) {
+		switch {
+		case p < len(pattern) && pattern[p] == "**":
+			if p == len(pattern)-1 {
+				return true
+			}
+			star, starC = p, c
+			p++
+		case p < len(pattern) && matchName(pattern[p], path[c], foldCase):
+			p, c = p+1, c+1
+		case star >= 0:
+			starC++
+			p, c = star+1, starC
+		default:
+			return false
+		}
+	}
+	// What remains of the pattern must match nothing: any "**" but a trailing
+	// one, which needs something inside its directory.
+	for ; p < len(pattern); p++ {
+		if pattern[p] != "**" || p == len(pattern)-1 {
+			return false
+		}
+	}
+	return true
+}
+
+// matchName reports whether the glob pattern matches the single path
+// component name, without regard to case if foldCase is set. Malformed globs
+// match nothing.
+func matchName(pattern, name string, foldCase bool) bool {
+	if foldCase {
+		pattern, name = strings.ToLower(pattern), strings.ToLower(name)
+	}
+	match, err := filepath.Match(pattern, name)
+	return err == nil && match
+}
+
+// rebasePatterns returns copies of sps, as read from source and rooted at
+// domain.
+func rebasePatterns(sps []SourcedPattern, source string, domain []string) []SourcedPattern {
+	domain = append([]string(nil), domain...)
+	rebased := make([]SourcedPattern, len(sps))
+	for i, sp := range sps {
+		p := *sp.Pattern.(*pattern)
+		p.domain = domain
+		rebased[i] = SourcedPattern{Pattern: &p, Text: sp.Text, Source: source, Line: sp.Line}
+	}
+	return rebased
+}
+
+// posixClasses maps the names of the POSIX character classes git supports in
+// bracket expressions to equivalent contents of a filepath.Match class.
+var posixClasses = map[string]string{
+	"alnum":  "0-9A-Za-z",
+	"alpha":  "A-Za-z",
+	"blank":  " \t",
+	"cntrl":  "\x00-\x1f\x7f",
+	"digit":  "0-9",
+	"graph":  "!-~",
+	"lower":  "a-z",
+	"print":  " -~",
+	"punct":  "!-/:-@\\[-`{-~",
+	"space":  "\t-\r ",
+	"upper":  "A-Z",
+	"xdigit": "0-9A-Fa-f",
+}
+
+// translateGlob rewrites a glob in git's syntax into the syntax of
+// filepath.Match. Git's bracket expressions may be negated by "!" as well as
+// "^", may begin with a literal "]", and may contain POSIX character classes
+// such as "[:digit:]". As in git, a glob with an unclosed bracket expression,
+// or one naming an unknown class, matches nothing.
+func translateGlob(glob string) string {
+	if !strings.Contains(glob, "[") {
+		return glob
+	}
+	rs := []rune(glob)
+	var b strings.Builder
+	for i := 0; i < len(rs); i++ {
+		switch rs[i] {
+		case '\\':
+			b.WriteRune(rs[i])
+			if i+1 < len(rs) {
+				i++
+				b.WriteRune(rs[i])
+			}
+		case '[':
+			n, ok := translateBracket(&b, rs[i:])
+			if !ok {
+				// An unterminated class, which filepath.Match rejects.
+				return "["
+			}
+			i += n - 1
+		default:
+			b.WriteRune(rs[i])
+		}
+	}
+	return b.String()
+}
+
+// translateBracket writes the filepath.Match form of the bracket expression
+// at the start of rs to b, returning the number of runes it spans, or false if
+// it is malformed.
+func translateBracket(b *strings.Builder, rs []rune) (int, bool) {
+	literal := func(r rune) {
+		if strings.ContainsRune(`\-]^[`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+
+	b.WriteByte('[')
+	i := 1
+	if i < len(rs) && (rs[i] == '!' || rs[i] == '^') {
+		b.WriteByte('^')
+		i++
+	}
+	for start := i; i < len(rs); i++ {
+		r := rs[i]
+		if r == ']' && i > start {
+			b.WriteByte(']')
+			return i + 1, true
+		}
+		if r == '[' && i+1 < len(rs) && rs[i+1] == ':' {
+			if end := strings.Index(string(rs[i+2:]), ":]"); end >= 0 {
+				name := string(rs[i+2:])[:end]
+				class, ok := posixClasses[name]
+				if !ok {
+					return 0, false
+				}
+				b.WriteString(class)
+				i += 2 + len([]rune(name)) + 1
+				continue
+			}
+		}
+		if r == '\\' && i+1 < len(rs) {
+			i++
+			r = rs[i]
+		}
+		literal(r)
+		if i+2 < len(rs) && rs[i+1] == '-' && rs[i+2] != ']' {
+			i += 2
+			hi := rs[i]
+			if hi == '\\' && i+1 < len(rs) {
+				i++
+				hi = rs[i]
+			}
+			b.WriteByte('-')
+			literal(hi)
+		}
+	}
+	return 0, false
+}
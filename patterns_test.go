@@ -0,0 +1,171 @@
+package walkrepo
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+)
+
+func TestReadPatternsLineNumbers(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"sub/.gitignore": "# build output\n\nbuild/\n# logs\n*.log\n\n\n!keep.log\n",
+	})
+	path := filepath.Join(root, "sub", ".gitignore")
+
+	sps, err := ReadPatterns(path, []string{"sub"})
+	if err != nil {
+		t.Fatalf("ReadPatterns() error = %v", err)
+	}
+
+	type provenance struct {
+		Text   string
+		Source string
+		Line   int
+	}
+	var got []provenance
+	for _, sp := range sps {
+		got = append(got, provenance{sp.Text, sp.Source, sp.Line})
+	}
+	want := []provenance{
+		{"build/", path, 3},
+		{"*.log", path, 5},
+		{"!keep.log", path, 8},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadPatterns() = %+v, want %+v", got, want)
+	}
+
+	// The patterns are rooted at the given domain.
+	if sps[1].Match([]string{"sub", "debug.log"}, false) != gitignore.Exclude {
+		t.Errorf("pattern %q did not match within its domain", sps[1].Text)
+	}
+	if sps[1].Match([]string{"other", "debug.log"}, false) != gitignore.NoMatch {
+		t.Errorf("pattern %q matched outside its domain", sps[1].Text)
+	}
+}
+
+func TestEvaluatePattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		isDir   bool
+		want    bool
+	}{
+		{"*.log", "debug.log", false, true},
+		// As in git, a leading "./" isn't normalized away, so it matches
+		// nothing: no path has a "." component.
+		{"./build", "build", true, false},
+		{"./build", "build/a", false, false},
+		{"./build/", "build", true, false},
+		{"sub/./build", "sub/build", true, false},
+		// A bare "**" matches everything, at any depth.
+		{"**", "a.txt", false, true},
+		{"**", "sub", true, true},
+		{"**", "sub/deep/a.txt", false, true},
+		// Bracket expressions, as decided by git check-ignore.
+		{"file[0-9].txt", "file1.txt", false, true},
+		{"file[0-9].txt", "fileA.txt", false, false},
+		{"file[0-9].txt", "file10.txt", false, false},
+		{"[[:digit:]]*.log", "9x.log", false, true},
+		{"[[:digit:]]*.log", "x9.log", false, false},
+		{"[!a]*.tmp", "a.tmp", false, false},
+		{"[!a]*.tmp", "b.tmp", false, true},
+		{"[^b]*.dat", "a.dat", false, true},
+		{"[^b]*.dat", "b.dat", false, false},
+		{"[[:upper:]][[:lower:]]*.md", "Readme.md", false, true},
+		{"[[:upper:]][[:lower:]]*.md", "README.md", false, false},
+		{"[[:upper:]][[:lower:]]*.md", "readme.md", false, false},
+		{"[[:bogus:]]x", "ax", false, false},
+		{"[[:punct:]]p", "_p", false, true},
+		{"[[:punct:]]p", "ap", false, false},
+		{"[a-c[:digit:]]q", "aq", false, true},
+		{"[a-c[:digit:]]q", "5q", false, true},
+		{"[a-c[:digit:]]q", "dq", false, false},
+		{"[]]r", "]r", false, true},
+		{"[]]r", "xr", false, false},
+		{"[a-]s", "-s", false, true},
+		{"[[:space:][:xdigit:]]t", " t", false, true},
+		{"[[:space:][:xdigit:]]t", "ft", false, true},
+		{"[[:space:][:xdigit:]]t", "gt", false, false},
+		{"unclosed[a", "unclosed[a", false, false},
+		{"*.log", "logs/debug.log", false, true},
+		{"*.log", "debug.txt", false, false},
+		{"build/", "build", true, true},
+		{"build/", "build", false, false},
+		{"/top", "top", false, true},
+		{"/top", "sub/top", false, false},
+		{"doc/**/*.md", "doc/a/b/c.md", false, true},
+		{"!keep.log", "keep.log", false, false},
+		{"# comment", "# comment", false, false},
+		{"", "anything", false, false},
+		{"\\#hash", "#hash", false, true},
+	}
+	for _, tt := range tests {
+		if got := EvaluatePattern(tt.pattern, tt.path, tt.isDir); got != tt.want {
+			t.Errorf("EvaluatePattern(%q, %q, %v) = %v, want %v", tt.pattern, tt.path, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestEvaluatePatternManyDoubleStars(t *testing.T) {
+	deep := strings.Repeat("x/", 40)
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"a/" + strings.Repeat("**/", 8) + "z", "a/" + deep + "y", false},
+		{"a/" + strings.Repeat("**/", 8) + "z", "a/" + deep + "z", true},
+		{"a/" + strings.Repeat("**/x/", 10) + "z", "a/" + deep + "y", false},
+		{"a/" + strings.Repeat("**/x/", 10) + "z", "a/" + deep + "z", true},
+		{strings.Repeat("**/", 30) + "y/**", deep + "y", false},
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, tt := range tests {
+			if got := EvaluatePattern(tt.pattern, tt.path, false); got != tt.want {
+				t.Errorf("EvaluatePattern(%q, %q, false) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+			}
+		}
+	}()
+	// Backtracking over every way of dividing the path between the "**"s
+	// would take far longer than this.
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("EvaluatePattern() did not finish within 10s")
+	}
+}
+
+func FuzzEvaluatePattern(f *testing.F) {
+	f.Add("*.log", "logs/debug.log", false)
+	f.Add("doc/**/*.md", "doc/a/b.md", false)
+	f.Add("!build/", "build", true)
+	f.Add("[a-", "a", false)
+	f.Add("a/**/b/**", "a/b", true)
+	f.Add("\\!x ", "!x", false)
+	f.Add("/", "/", true)
+
+	f.Fuzz(func(t *testing.T, pattern, path string, isDir bool) {
+		excluded := EvaluatePattern(pattern, path, isDir)
+
+		// Whatever the input, a negation or a comment never excludes.
+		if EvaluatePattern("!"+pattern, path, isDir) {
+			t.Errorf("negation %q excluded %q", "!"+pattern, path)
+		}
+		if EvaluatePattern("#"+pattern, path, isDir) {
+			t.Errorf("comment %q excluded %q", "#"+pattern, path)
+		}
+
+		// Evaluation is deterministic.
+		if EvaluatePattern(pattern, path, isDir) != excluded {
+			t.Errorf("EvaluatePattern(%q, %q, %v) is not deterministic", pattern, path, isDir)
+		}
+	})
+}
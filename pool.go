@@ -0,0 +1,75 @@
+package walkrepo
+
+import (
+	"errors"
+	"os"
+	"sync"
+)
+
+// errPoolStopped aborts the traversal of WalkRepoPool once a worker fails.
+var errPoolStopped = errors.New("walkrepo: pool stopped")
+
+// WalkRepoPool walks root as WalkRepo does, dispatching each non-ignored file
+// (but not directory) to one of workers goroutines running fn. Traversal
+// itself remains serial and ordered; only the per-file work is parallel.
+//
+// fn must be safe for concurrent use. The first error returned by fn stops
+// the walk, and is returned once in-flight calls have finished.
+func WalkRepoPool(root string, workers int, fn func(path string, info os.FileInfo) error, opts ...Option) error {
+	if workers < 1 {
+		workers = 1
+	}
+
+	type job struct {
+		path string
+		info os.FileInfo
+	}
+	jobs := make(chan job)
+	failed := make(chan struct{})
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	fail := func(err error) {
+		once.Do(func() {
+			firstErr = err
+			close(failed)
+		})
+	}
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				if err := fn(j.path, j.info); err != nil {
+					fail(err)
+				}
+			}
+		}()
+	}
+
+	err := WalkRepo(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		select {
+		case jobs <- job{path, info}:
+			return nil
+		case <-failed:
+			return errPoolStopped
+		}
+	}, opts...)
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return err
+}
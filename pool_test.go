@@ -0,0 +1,77 @@
+package walkrepo
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWalkRepoPool(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{".gitignore": "*.log"}
+	for i := 0; i < 50; i++ {
+		files[fmt.Sprintf("dir%d/file%d.txt", i%5, i)] = fmt.Sprintf("content %d", i)
+		files[fmt.Sprintf("dir%d/file%d.log", i%5, i)] = "ignored"
+	}
+	writeTree(t, root, files)
+
+	var mu sync.Mutex
+	hashes := make(map[string][sha256.Size]byte)
+	err := WalkRepoPool(root, 4, func(path string, info os.FileInfo) error {
+		if info.IsDir() {
+			t.Errorf("directory %q was dispatched to the pool", path)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(content)
+		mu.Lock()
+		defer mu.Unlock()
+		rel, _ := filepath.Rel(root, path)
+		hashes[filepath.ToSlash(rel)] = sum
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkRepoPool() error = %v", err)
+	}
+
+	if len(hashes) != 50 {
+		t.Errorf("processed %d files, want 50", len(hashes))
+	}
+	for i := 0; i < 50; i++ {
+		path := fmt.Sprintf("dir%d/file%d.txt", i%5, i)
+		if hashes[path] != sha256.Sum256([]byte(fmt.Sprintf("content %d", i))) {
+			t.Errorf("file %q was not hashed correctly", path)
+		}
+	}
+}
+
+func TestWalkRepoPoolError(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{}
+	for i := 0; i < 100; i++ {
+		files[fmt.Sprintf("file%d.txt", i)] = "content"
+	}
+	writeTree(t, root, files)
+
+	boom := errors.New("boom")
+	var calls int32
+	err := WalkRepoPool(root, 3, func(path string, info os.FileInfo) error {
+		if atomic.AddInt32(&calls, 1) == 10 {
+			return boom
+		}
+		return nil
+	})
+	if err != boom {
+		t.Errorf("WalkRepoPool() error = %v, want %v", err, boom)
+	}
+	if n := atomic.LoadInt32(&calls); n >= 100 {
+		t.Errorf("walk processed all %d files despite an error", n)
+	}
+}
@@ -0,0 +1,41 @@
+package walkrepo
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// WalkRepoPorcelain writes to w the path of each non-ignored file (but not
+// directory) beneath root, relative to root and slash-separated, each
+// terminated by a NUL byte, as `git ls-files -z` does. Paths are written
+// verbatim, so that those holding spaces, quotes or newlines survive pipelines
+// such as `xargs -0`.
+func WalkRepoPorcelain(w io.Writer, root string, opts ...Option) error {
+	root, opts, err := rootRelative(root, opts)
+	if err != nil {
+		return err
+	}
+	out := bufio.NewWriter(w)
+	err = WalkRepo(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if _, err := out.WriteString(filepath.ToSlash(rel)); err != nil {
+			return err
+		}
+		return out.WriteByte(0)
+	}, opts...)
+	if err != nil {
+		return err
+	}
+	return out.Flush()
+}
@@ -0,0 +1,58 @@
+package walkrepo
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestWalkRepoPorcelain(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":          "*.log\n",
+		"plain.txt":           "content",
+		"with space.txt":      "content",
+		"sub dir/'quoted'.go": "content",
+		"sub dir/debug.log":   "content",
+	})
+	want := []string{"plain.txt", "sub dir/'quoted'.go", "with space.txt"}
+	if err := os.WriteFile(filepath.Join(root, "new\nline.txt"), []byte("content"), 0o644); err != nil {
+		t.Logf("newlines in file names unsupported: %v", err)
+	} else {
+		want = append(want, "new\nline.txt")
+	}
+	sort.Strings(want)
+
+	var buf bytes.Buffer
+	if err := WalkRepoPorcelain(&buf, root); err != nil {
+		t.Fatalf("WalkRepoPorcelain() error = %v", err)
+	}
+	out := buf.String()
+	if !strings.HasSuffix(out, "\x00") {
+		t.Fatalf("WalkRepoPorcelain() output %q is not NUL-terminated", out)
+	}
+	got := strings.Split(strings.TrimSuffix(out, "\x00"), "\x00")
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("WalkRepoPorcelain() records = %q, want %q", got, want)
+	}
+}
+
+func TestWalkRepoPorcelainEmpty(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore": "*\n",
+		"a.txt":      "content",
+	})
+	var buf bytes.Buffer
+	if err := WalkRepoPorcelain(&buf, root); err != nil {
+		t.Fatalf("WalkRepoPorcelain() error = %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("WalkRepoPorcelain() output = %q, want none", buf.String())
+	}
+}
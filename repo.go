@@ -0,0 +1,40 @@
+package walkrepo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ErrNoRepo is returned when no git repository encloses a path.
+var ErrNoRepo = errors.New("walkrepo: no git repository found")
+
+// FindRepoRoot returns the root of the git repository enclosing start: the
+// nearest of start (or, for a file, its directory) and its ancestors to
+// contain a .git directory (or, as in worktrees and submodules, a .git file).
+// The root is returned as an absolute path. If there is no such repository,
+// the error wraps ErrNoRepo.
+func FindRepoRoot(start string) (string, error) {
+	dir, err := filepath.Abs(start)
+	if err != nil {
+		return "", err
+	}
+	if info, err := os.Stat(dir); err == nil && !info.IsDir() {
+		dir = filepath.Dir(dir)
+	}
+	for {
+		_, err := os.Lstat(filepath.Join(dir, ".git"))
+		if err == nil {
+			return dir, nil
+		} else if !os.IsNotExist(err) {
+			return "", err
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("%w at or above %s", ErrNoRepo, start)
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,319 @@
+package walkrepo
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+)
+
+func TestFindRepoRoot(t *testing.T) {
+	tmpDir := t.TempDir()
+	root := filepath.Join(tmpDir, "repo")
+	writeTree(t, root, map[string]string{
+		".git/HEAD":            "ref: refs/heads/main",
+		"pkg/deep/nested/a.go": "content",
+		"sub/.git":             "gitdir: ../.git/modules/sub",
+		"sub/lib/b.go":         "content",
+	})
+
+	tests := []struct {
+		start string
+		want  string
+	}{
+		{root, root},
+		{filepath.Join(root, "pkg", "deep", "nested"), root},
+		{filepath.Join(root, "pkg", "deep", "nested", "a.go"), root},
+		{filepath.Join(root, "sub", "lib"), filepath.Join(root, "sub")},
+	}
+	for _, tt := range tests {
+		got, err := FindRepoRoot(tt.start)
+		if err != nil {
+			t.Errorf("FindRepoRoot(%q) error = %v", tt.start, err)
+		} else if got != tt.want {
+			t.Errorf("FindRepoRoot(%q) = %q, want %q", tt.start, got, tt.want)
+		}
+	}
+
+	t.Run("relative start", func(t *testing.T) {
+		chdir(t, filepath.Join(root, "pkg"))
+		got, err := FindRepoRoot("deep")
+		if err != nil || got != root {
+			t.Errorf("FindRepoRoot(%q) = %q, %v; want %q", "deep", got, err, root)
+		}
+	})
+
+	t.Run("no repository", func(t *testing.T) {
+		// The temporary directory may itself lie within a repository, so
+		// only a missing repository is checked for the expected error.
+		plain := filepath.Join(tmpDir, "plain")
+		writeTree(t, plain, map[string]string{"a.txt": "content"})
+		if _, err := FindRepoRoot(plain); err != nil && !errors.Is(err, ErrNoRepo) {
+			t.Errorf("FindRepoRoot() error = %v, want ErrNoRepo", err)
+		}
+	})
+}
+
+func TestWithDiscoverRoot(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".git/HEAD":     "ref: refs/heads/main",
+		".gitignore":    "*.log\n.git/\n",
+		"top.txt":       "content",
+		"pkg/a.go":      "content",
+		"pkg/debug.log": "content",
+	})
+
+	walked := make(map[string]bool)
+	err := WalkRepo(filepath.Join(root, "pkg"), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !filepath.IsAbs(path) {
+			t.Errorf("path %q is not absolute", path)
+		}
+		rel, _ := filepath.Rel(root, path)
+		walked[filepath.ToSlash(rel)] = true
+		return nil
+	}, WithDiscoverRoot(true))
+	if err != nil {
+		t.Fatalf("WalkRepo() error = %v", err)
+	}
+	assertWalked(t, walked, []string{"top.txt", "pkg", "pkg/a.go"}, []string{".git", "pkg/debug.log"})
+}
+
+func TestGitFeaturesWithoutRepo(t *testing.T) {
+	// An empty home, so that the user's own global excludes don't apply.
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":     "*.log\n",
+		"a.txt":          "content",
+		"a.log":          "content",
+		"sub/.gitignore": "*.tmp\n",
+		"sub/b.tmp":      "content",
+		"sub/b.txt":      "content",
+	})
+
+	walked := make(map[string]bool)
+	err := WalkTracked(filepath.Join(root, "sub"), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		walked[filepath.ToSlash(rel)] = true
+		return nil
+	}, WithDiscoverRoot(true))
+	if err != nil {
+		t.Fatalf("WalkTracked() error = %v", err)
+	}
+	// Without a repository to discover, the given root is walked.
+	assertWalked(t, walked, []string{"sub/b.txt"}, []string{"a.txt", "sub/b.tmp"})
+
+	walked = make(map[string]bool)
+	err = WalkTracked(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		walked[filepath.ToSlash(rel)] = true
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkTracked() error = %v", err)
+	}
+	assertWalked(t, walked, []string{"a.txt", "sub", "sub/b.txt"}, []string{"a.log", "sub/b.tmp"})
+}
+
+func TestWithRequireRepo(t *testing.T) {
+	tmpDir := t.TempDir()
+	plain := filepath.Join(tmpDir, "plain")
+	repo := filepath.Join(tmpDir, "repo")
+	writeTree(t, tmpDir, map[string]string{
+		"plain/a.txt":    "content",
+		"repo/.git/HEAD": "ref: refs/heads/main",
+		"repo/sub/b.txt": "content",
+	})
+	if _, err := FindRepoRoot(plain); err == nil {
+		t.Skip("the temporary directory lies within a repository")
+	}
+
+	for _, discover := range []bool{false, true} {
+		called := false
+		err := WalkRepo(plain, func(path string, info os.FileInfo, err error) error {
+			called = true
+			return err
+		}, WithRequireRepo(true), WithDiscoverRoot(discover))
+		if !errors.Is(err, ErrNoRepo) {
+			t.Errorf("WalkRepo() with discovery %v error = %v, want ErrNoRepo", discover, err)
+		}
+		if called {
+			t.Errorf("WalkRepo() with discovery %v walked a plain directory", discover)
+		}
+	}
+
+	if got := walkedPaths(t, plain, WithRequireRepo(false)); !got["a.txt"] {
+		t.Errorf("WalkRepo() without WithRequireRepo walked %v", got)
+	}
+	for _, root := range []string{repo, filepath.Join(repo, "sub")} {
+		if got := walkedPaths(t, root, WithRequireRepo(true)); len(got) == 0 {
+			t.Errorf("WalkRepo(%q) within a repository walked nothing", root)
+		}
+	}
+}
+
+func TestWithInfoExclude(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".git/info/exclude": "*.tmp\n/sub/anchored.txt\n/top.txt\n",
+		"a.tmp":             "content",
+		"top.txt":           "content",
+		"anchored.txt":      "content",
+		"sub/x.tmp":         "content",
+		"sub/anchored.txt":  "content",
+		"sub/top.txt":       "content",
+		"sub/keep.txt":      "content",
+	})
+
+	walked := walkedPaths(t, root, WithInfoExclude(true), WithSkipGit(true))
+	assertWalked(t, walked,
+		[]string{"anchored.txt", "sub", "sub/top.txt", "sub/keep.txt"},
+		[]string{"a.tmp", "top.txt", "sub/x.tmp", "sub/anchored.txt"})
+
+	// Walking a subdirectory, the patterns still anchor at the repository's
+	// root, as git status run there shows.
+	walked = walkedPaths(t, filepath.Join(root, "sub"), WithInfoExclude(true))
+	assertWalked(t, walked,
+		[]string{"top.txt", "keep.txt"},
+		[]string{"x.tmp", "anchored.txt"})
+}
+
+func TestWalkTrackedPrecedence(t *testing.T) {
+	// As git status decides: .gitignore overrides .git/info/exclude, which
+	// overrides the global excludes, negations included.
+	files := map[string]string{
+		".git/HEAD":         "ref: refs/heads/main",
+		".git/info/exclude": "!b.tmp\n!*.log\n*.bak\n",
+		".gitignore":        "f.log\n!c.bak\n",
+		"a.tmp":             "content",
+		"b.tmp":             "content",
+		"c.bak":             "content",
+		"d.bak":             "content",
+		"f.log":             "content",
+		"g.log":             "content",
+		"x.cache":           "content",
+		"keep.txt":          "content",
+	}
+	const global = "*.tmp\n*.log\n*.cache\n"
+
+	tests := []struct {
+		name  string
+		setup func(t *testing.T, home string)
+	}{
+		{"core.excludesFile", func(t *testing.T, home string) {
+			t.Setenv("XDG_CONFIG_HOME", "")
+			writeTree(t, home, map[string]string{
+				".gitconfig":    "[core]\n\texcludesFile = ~/global-ignore\n",
+				"global-ignore": global,
+			})
+		}},
+		{"XDG fallback", func(t *testing.T, home string) {
+			xdg := filepath.Join(home, "xdg")
+			t.Setenv("XDG_CONFIG_HOME", xdg)
+			writeTree(t, xdg, map[string]string{"git/ignore": global})
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			home := t.TempDir()
+			t.Setenv("HOME", home)
+			tt.setup(t, home)
+
+			root := t.TempDir()
+			writeTree(t, root, files)
+			walked := make(map[string]bool)
+			err := WalkTracked(root, func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				rel, _ := filepath.Rel(root, path)
+				walked[filepath.ToSlash(rel)] = true
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("WalkTracked() error = %v", err)
+			}
+			assertWalked(t, walked,
+				[]string{"b.tmp", "c.bak", "g.log", "keep.txt"},
+				[]string{"a.tmp", "d.bak", "f.log", "x.cache", ".git"})
+		})
+	}
+}
+
+func TestWithRespectIndex(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".git/HEAD":        "ref: refs/heads/main",
+		".gitignore":       "build/\n*.log\n",
+		"sub/.gitignore":   "gen/\n",
+		"build/.gitignore": "!*.o\n",
+		"build/keep.o":     "content",
+		"build/other.o":    "content",
+		"build/deep/t.o":   "content",
+		"build/deep/u.o":   "content",
+		"debug.log":        "content",
+		"other.log":        "content",
+		"sub/gen/x.go":     "content",
+		"sub/gen/y.go":     "content",
+		"sub/main.go":      "content",
+	})
+	// The index of `git add -f` of the tracked files.
+	idx := &index.Index{Version: 2}
+	for _, name := range []string{"build/keep.o", "build/deep/t.o", "debug.log", "sub/gen/x.go"} {
+		idx.Add(name).Mode = filemode.Regular
+	}
+	f, err := os.Create(filepath.Join(root, ".git", "index"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := index.NewEncoder(f).Encode(idx); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	// Expectations taken from `git status --ignored` over the same tree. The
+	// negation in build/.gitignore can't re-include build/other.o, since
+	// build itself is excluded.
+	tracked := []string{"build", "build/keep.o", "build/deep", "build/deep/t.o", "debug.log", "sub/gen", "sub/gen/x.go", "sub/main.go"}
+	untracked := []string{"build/other.o", "build/deep/u.o", "other.log", "sub/gen/y.go"}
+	walked := walkedPaths(t, root, WithRespectIndex(true), WithSkipGit(true))
+	assertWalked(t, walked, tracked, untracked)
+
+	walked = walkedPaths(t, root, WithSkipGit(true))
+	assertWalked(t, walked, []string{"sub/main.go"}, append(untracked, "build", "debug.log", "sub/gen"))
+
+	t.Run("beneath the repository root", func(t *testing.T) {
+		walked := walkedPaths(t, filepath.Join(root, "sub"), WithRespectIndex(true))
+		assertWalked(t, walked, []string{"gen", "gen/x.go", "main.go"}, []string{"gen/y.go"})
+
+		walked = make(map[string]bool)
+		err := WalkSubtree(root, filepath.Join("build", "deep"), func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, _ := filepath.Rel(root, path)
+			walked[filepath.ToSlash(rel)] = true
+			return nil
+		}, WithRespectIndex(true))
+		if err != nil {
+			t.Fatalf("WalkSubtree() error = %v", err)
+		}
+		assertWalked(t, walked, []string{"build/deep/t.o"}, []string{"build/deep/u.o"})
+	})
+}
@@ -0,0 +1,20 @@
+package walkrepo
+
+import "os"
+
+// WalkRepoSplit walks root as WalkRepo does, passing directories to dirFn
+// and all other entries to fileFn. Either may return filepath.SkipDir or
+// filepath.SkipAll, with the meanings they have for WalkRepo: from dirFn,
+// SkipDir skips the directory's contents, and from fileFn, the rest of the
+// file's directory.
+func WalkRepoSplit(root string, dirFn, fileFn func(path string, info os.FileInfo) error, opts ...Option) error {
+	return WalkRepo(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return dirFn(path, info)
+		}
+		return fileFn(path, info)
+	}, opts...)
+}
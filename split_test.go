@@ -0,0 +1,107 @@
+package walkrepo
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestWalkRepoSplit(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":      "*.log\n",
+		"a.txt":           "content",
+		"a.log":           "content",
+		"sub/b.txt":       "content",
+		"sub/deep/c.txt":  "content",
+		"skip/d.txt":      "content",
+		"stop/1.txt":      "content",
+		"stop/2.txt":      "content",
+		"stop/3.txt":      "content",
+		"stop/inner/e.md": "content",
+	})
+
+	var order []string // every entry visited, in order
+	walk := func(dirResult, fileResult func(rel string) error) (dirs, files []string, err error) {
+		order = nil
+		rel := func(path string) string {
+			rel, _ := filepath.Rel(root, path)
+			return filepath.ToSlash(rel)
+		}
+		err = WalkRepoSplit(root, func(path string, info os.FileInfo) error {
+			if !info.IsDir() {
+				t.Errorf("dirFn passed non-directory %s", path)
+			}
+			dirs = append(dirs, rel(path))
+			order = append(order, rel(path))
+			return dirResult(rel(path))
+		}, func(path string, info os.FileInfo) error {
+			if info.IsDir() {
+				t.Errorf("fileFn passed directory %s", path)
+			}
+			files = append(files, rel(path))
+			order = append(order, rel(path))
+			return fileResult(rel(path))
+		}, WithDirSort(true))
+		return dirs, files, err
+	}
+	none := func(string) error { return nil }
+
+	dirs, files, err := walk(none, none)
+	if err != nil {
+		t.Fatalf("WalkRepoSplit() error = %v", err)
+	}
+	if want := []string{"skip", "stop", "stop/inner", "sub", "sub/deep"}; !reflect.DeepEqual(dirs, want) {
+		t.Errorf("dirFn passed %q, want %q", dirs, want)
+	}
+	if want := []string{"a.txt", "skip/d.txt", "stop/1.txt", "stop/2.txt", "stop/3.txt", "stop/inner/e.md", "sub/b.txt", "sub/deep/c.txt"}; !reflect.DeepEqual(files, want) {
+		t.Errorf("fileFn passed %q, want %q", files, want)
+	}
+
+	// SkipDir from dirFn skips the directory, and from fileFn, the rest of
+	// the file's directory.
+	dirs, files, err = walk(func(rel string) error {
+		if rel == "skip" {
+			return filepath.SkipDir
+		}
+		return nil
+	}, func(rel string) error {
+		if rel == "stop/1.txt" {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkRepoSplit() with SkipDir error = %v", err)
+	}
+	sort.Strings(files)
+	if want := []string{"a.txt", "stop/1.txt", "sub/b.txt", "sub/deep/c.txt"}; !reflect.DeepEqual(files, want) {
+		t.Errorf("with SkipDir, fileFn passed %q, want %q", files, want)
+	}
+
+	// SkipAll from either ends the walk without error.
+	skipAllAt := func(at string) func(string) error {
+		return func(rel string) error {
+			if rel == at {
+				return filepath.SkipAll
+			}
+			return nil
+		}
+	}
+	for _, tt := range []struct {
+		dirResult, fileResult func(string) error
+		last                  string
+	}{
+		{skipAllAt("stop"), none, "stop"},
+		{none, skipAllAt("stop/1.txt"), "stop/1.txt"},
+	} {
+		if _, _, err := walk(tt.dirResult, tt.fileResult); err != nil {
+			t.Fatalf("WalkRepoSplit() with SkipAll error = %v", err)
+		}
+		if len(order) == 0 || order[len(order)-1] != tt.last {
+			t.Errorf("with SkipAll at %s, visited %q", tt.last, order)
+		}
+	}
+}
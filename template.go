@@ -0,0 +1,56 @@
+package walkrepo
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WalkRepoFormatted walks root as WalkRepo does, passing fn the path of each
+// non-ignored file (but not directory), formatted by the template given to
+// WithPathTemplate, as for writing manifests or file lists. Without a
+// template, fn is passed paths relative to root.
+//
+// The template is applied to paths beneath root (or the repository root,
+// with WithDiscoverRoot) whatever the options, so WithRelativeTo and
+// WithAbsolutePaths have no effect; use the {rel} and {abs} tokens instead.
+func WalkRepoFormatted(root string, fn func(formatted string) error, opts ...Option) error {
+	opts = append(opts[:len(opts):len(opts)], func(c *config) {
+		c.relativeTo, c.absolutePaths = "", true
+	})
+	cfg := newConfig(opts)
+	tmpl := cfg.pathTemplate
+	if tmpl == "" {
+		tmpl = "{rel}"
+	}
+	absRoot, err := cfg.walkRoot(root)
+	if err != nil {
+		return err
+	}
+	return WalkRepo(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(absRoot, path)
+		if err != nil {
+			return err
+		}
+		return fn(formatPath(tmpl, path, rel))
+	}, opts...)
+}
+
+// formatPath substitutes the tokens of tmpl with the parts of the file at the
+// absolute path abs, whose path relative to the root is rel.
+func formatPath(tmpl, abs, rel string) string {
+	name := filepath.Base(rel)
+	return strings.NewReplacer(
+		"{rel}", rel,
+		"{abs}", abs,
+		"{name}", name,
+		"{ext}", filepath.Ext(name),
+		"{dir}", filepath.Dir(rel),
+	).Replace(tmpl)
+}
@@ -0,0 +1,101 @@
+package walkrepo
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestWalkRepoFormatted(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":     "*.log\n",
+		"a.txt":          "content",
+		"debug.log":      "content",
+		"sub/b.tar.gz":   "content",
+		"sub/Makefile":   "content",
+		"sub/deep/c.go":  "content",
+		"sub/deep/c.log": "content",
+	})
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	native := filepath.FromSlash
+
+	tests := []struct {
+		name string
+		opts []Option
+		want []string
+	}{
+		{"default", nil, []string{"a.txt", native("sub/Makefile"), native("sub/b.tar.gz"), native("sub/deep/c.go")}},
+		{"rel", []Option{WithPathTemplate("{rel}")}, []string{"a.txt", native("sub/Makefile"), native("sub/b.tar.gz"), native("sub/deep/c.go")}},
+		{"abs", []Option{WithPathTemplate("{abs}")}, []string{
+			filepath.Join(absRoot, "a.txt"),
+			filepath.Join(absRoot, "sub", "Makefile"),
+			filepath.Join(absRoot, "sub", "b.tar.gz"),
+			filepath.Join(absRoot, "sub", "deep", "c.go"),
+		}},
+		{"name and ext", []Option{WithPathTemplate("{name} [{ext}]")}, []string{"Makefile []", "a.txt [.txt]", "b.tar.gz [.gz]", "c.go [.go]"}},
+		{"dir", []Option{WithPathTemplate("{dir}: {name}")}, []string{
+			".: a.txt",
+			native("sub/deep") + ": c.go",
+			native("sub") + ": Makefile",
+			native("sub") + ": b.tar.gz",
+		}},
+		{"repeated and unknown tokens", []Option{WithPathTemplate("{name}{name} {size} {ext")}, []string{
+			"MakefileMakefile {size} {ext",
+			"a.txta.txt {size} {ext",
+			"b.tar.gzb.tar.gz {size} {ext",
+			"c.goc.go {size} {ext",
+		}},
+		{"literal text", []Option{WithPathTemplate("files/{rel}")}, []string{
+			"files/a.txt", "files/" + native("sub/Makefile"), "files/" + native("sub/b.tar.gz"), "files/" + native("sub/deep/c.go"),
+		}},
+		{"ignores relative-to", []Option{WithPathTemplate("{rel}"), WithRelativeTo(filepath.Dir(root))}, []string{
+			"a.txt", native("sub/Makefile"), native("sub/b.tar.gz"), native("sub/deep/c.go"),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			err := WalkRepoFormatted(root, func(formatted string) error {
+				got = append(got, formatted)
+				return nil
+			}, tt.opts...)
+			if err != nil {
+				t.Fatalf("WalkRepoFormatted() error = %v", err)
+			}
+			sort.Strings(got)
+			sort.Strings(tt.want)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("WalkRepoFormatted() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("relative root", func(t *testing.T) {
+		chdir(t, root)
+		var got []string
+		err := WalkRepoFormatted(".", func(formatted string) error {
+			got = append(got, formatted)
+			return nil
+		}, WithPathTemplate("{abs}"), WithSimpleExcludes("sub"))
+		if err != nil {
+			t.Fatalf("WalkRepoFormatted() error = %v", err)
+		}
+		if want := []string{filepath.Join(absRoot, "a.txt")}; !reflect.DeepEqual(got, want) {
+			t.Errorf("WalkRepoFormatted() = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("callback error", func(t *testing.T) {
+		boom := errors.New("boom")
+		err := WalkRepoFormatted(root, func(string) error { return boom })
+		if !errors.Is(err, boom) {
+			t.Errorf("WalkRepoFormatted() error = %v, want %v", err, boom)
+		}
+	})
+}
@@ -0,0 +1,80 @@
+package walkrepo
+
+import (
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+)
+
+// Decision is the evaluation of a single pattern in a DecisionTrace.
+type Decision struct {
+	// Path is the path the pattern was evaluated against, relative to the
+	// root with forward slashes: the traced path, or one of the directories
+	// containing it.
+	Path string
+	// Source, Line and Pattern identify the pattern, as in CheckResult.
+	Source  string
+	Line    int
+	Pattern string
+	// Matched reports whether the pattern matched Path.
+	Matched bool
+	// Decisive reports whether the pattern decided the fate of Path, being
+	// the last of the patterns in effect to match it.
+	Decisive bool
+}
+
+// DecisionTrace explains how the ignore rules in effect beneath root decide
+// whether path is ignored, taking path as CheckIgnore does. It lists every
+// pattern evaluated, in the order of precedence in which the patterns apply,
+// lowest first, together with whether each matched.
+//
+// As in git, each directory containing path is decided before path itself,
+// each against the patterns in effect within its parent. Directories which
+// no pattern excludes are followed by the next level down; if one is
+// excluded, the trace ends with it, path being ignored along with it.
+// Otherwise the trace ends with the patterns evaluated against path, of
+// which the decisive one, if any, decided it.
+func DecisionTrace(root, path string, opts ...Option) ([]Decision, error) {
+	c, err := newIgnoreChecker(root, newConfig(opts))
+	if err != nil {
+		return nil, err
+	}
+	components, isDir, err := c.components(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var trace []Decision
+	patterns := c.base
+	for i := range components {
+		sps, err := c.dirPatterns(components[:i])
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns[:len(patterns):len(patterns)], sps...)
+
+		level := components[:i+1]
+		last := i == len(components)-1
+		decisive, decision := -1, gitignore.NoMatch
+		for _, sp := range patterns {
+			match := matchPattern(sp, c.domain(level), isDir || !last, c.cfg.ignoreCase)
+			if match != gitignore.NoMatch {
+				decisive, decision = len(trace), match
+			}
+			trace = append(trace, Decision{
+				Path:    strings.Join(level, "/"),
+				Source:  sp.Source,
+				Line:    sp.Line,
+				Pattern: sp.Text,
+				Matched: match != gitignore.NoMatch,
+			})
+		}
+		if decisive >= 0 {
+			trace[decisive].Decisive = true
+		}
+		if decision == gitignore.Exclude {
+			break
+		}
+	}
+	return trace, nil
+}
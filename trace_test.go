@@ -0,0 +1,99 @@
+package walkrepo
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDecisionTrace(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":     "*.log\nbuild/\n!app.log\n",
+		"sub/.gitignore": "app.log\n/other\n",
+		"sub/app.log":    "content",
+		"build/out.log":  "content",
+	})
+	rootIgnore := filepath.Join(root, ".gitignore")
+	subIgnore := filepath.Join(root, "sub", ".gitignore")
+
+	type step struct {
+		Path     string
+		Line     int
+		Matched  bool
+		Decisive bool
+	}
+	summarize := func(trace []Decision) []step {
+		var steps []step
+		for _, d := range trace {
+			steps = append(steps, step{d.Path, d.Line, d.Matched, d.Decisive})
+		}
+		return steps
+	}
+
+	t.Run("toggled by several patterns", func(t *testing.T) {
+		trace, err := DecisionTrace(root, filepath.Join("sub", "app.log"))
+		if err != nil {
+			t.Fatalf("DecisionTrace() error = %v", err)
+		}
+		// sub matches nothing, so app.log within it is decided by all five
+		// patterns: excluded by *.log, re-included by !app.log, and excluded
+		// again by sub's app.log.
+		want := []step{
+			{"sub", 1, false, false},
+			{"sub", 2, false, false},
+			{"sub", 3, false, false},
+			{"sub/app.log", 1, true, false},
+			{"sub/app.log", 2, false, false},
+			{"sub/app.log", 3, true, false},
+			{"sub/app.log", 1, true, true},
+			{"sub/app.log", 2, false, false},
+		}
+		if got := summarize(trace); !reflect.DeepEqual(got, want) {
+			t.Errorf("DecisionTrace() = %+v, want %+v", got, want)
+		}
+		if d := trace[6]; d.Source != subIgnore || d.Pattern != "app.log" {
+			t.Errorf("decisive pattern = %+v, want app.log from %s", d, subIgnore)
+		}
+		if d := trace[5]; d.Source != rootIgnore || d.Pattern != "!app.log" {
+			t.Errorf("re-including pattern = %+v, want !app.log from %s", d, rootIgnore)
+		}
+	})
+
+	t.Run("within an excluded directory", func(t *testing.T) {
+		trace, err := DecisionTrace(root, filepath.Join("build", "out.log"))
+		if err != nil {
+			t.Fatalf("DecisionTrace() error = %v", err)
+		}
+		// The trace ends with build, which is excluded with its contents.
+		want := []step{
+			{"build", 1, false, false},
+			{"build", 2, true, true},
+			{"build", 3, false, false},
+		}
+		if got := summarize(trace); !reflect.DeepEqual(got, want) {
+			t.Errorf("DecisionTrace() = %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("matched by nothing", func(t *testing.T) {
+		trace, err := DecisionTrace(root, "readme.md")
+		if err != nil {
+			t.Fatalf("DecisionTrace() error = %v", err)
+		}
+		if len(trace) != 3 {
+			t.Errorf("DecisionTrace() = %+v, want the three root patterns", trace)
+		}
+		for _, d := range trace {
+			if d.Matched || d.Decisive {
+				t.Errorf("DecisionTrace() step %+v matched", d)
+			}
+		}
+	})
+
+	t.Run("outside root", func(t *testing.T) {
+		if _, err := DecisionTrace(root, filepath.Join("..", "x")); err == nil {
+			t.Errorf("DecisionTrace() of a path outside root succeeded")
+		}
+	})
+}
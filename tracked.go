@@ -0,0 +1,147 @@
+package walkrepo
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	formatconfig "github.com/go-git/go-git/v5/plumbing/format/config"
+)
+
+// WalkTracked walks root as WalkRepo does, but layers the ignore sources git
+// itself consults, in git's order of precedence: the user's global excludes
+// file (core.excludesFile), then the repository's .git/info/exclude, then the
+// tree's .gitignore files. The .git directory itself is skipped.
+//
+// The result approximates the files git would consider part of the working
+// tree. It is only an approximation: files which are tracked despite matching
+// an ignore pattern are still skipped, unless WithRespectIndex is given to
+// consult git's index.
+func WalkTracked(root string, walkFn filepath.WalkFunc, opts ...Option) error {
+	opts = append([]Option{
+		WithGlobalExcludes(true),
+		WithInfoExclude(true),
+		WithSkipGit(true),
+	}, opts...)
+	return WalkRepo(root, walkFn, opts...)
+}
+
+// globalExcludesFile returns the path of the user's global excludes file, as
+// git finds it: the core.excludesFile setting of the user's git config, or
+// failing that, $XDG_CONFIG_HOME/git/ignore. It returns "" if neither can be
+// determined.
+func globalExcludesFile() (string, error) {
+	home, _ := os.UserHomeDir()
+	xdg := os.Getenv("XDG_CONFIG_HOME")
+	if xdg == "" && home != "" {
+		xdg = filepath.Join(home, ".config")
+	}
+
+	// Later config files take precedence, as in git.
+	var configFiles []string
+	if xdg != "" {
+		configFiles = append(configFiles, filepath.Join(xdg, "git", "config"))
+	}
+	if home != "" {
+		configFiles = append(configFiles, filepath.Join(home, ".gitconfig"))
+	}
+
+	excludesFile := ""
+	for _, path := range configFiles {
+		f, err := os.Open(path)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return "", err
+		}
+		cfg := formatconfig.New()
+		err = formatconfig.NewDecoder(f).Decode(cfg)
+		f.Close()
+		if err != nil {
+			return "", err
+		}
+		if value := cfg.Section("core").Options.Get("excludesfile"); value != "" {
+			excludesFile = value
+		}
+	}
+
+	if strings.HasPrefix(excludesFile, "~/") && home != "" {
+		excludesFile = filepath.Join(home, excludesFile[2:])
+	}
+	if excludesFile == "" && xdg != "" {
+		excludesFile = filepath.Join(xdg, "git", "ignore")
+	}
+	return excludesFile, nil
+}
+
+// gitDir returns the git directory of the repository rooted at root,
+// following a .git file to the directory it names. It returns "" if root has
+// no .git.
+func gitDir(root string) (string, error) {
+	dotGit := filepath.Join(root, ".git")
+	info, err := os.Stat(dotGit)
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return dotGit, nil
+	}
+
+	content, err := os.ReadFile(dotGit)
+	if err != nil {
+		return "", err
+	}
+	dir := strings.TrimSpace(strings.TrimPrefix(string(content), "gitdir:"))
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(root, dir)
+	}
+	return dir, nil
+}
+
+// infoExcludePatterns returns the patterns of the .git/info/exclude of the
+// repository enclosing root, as found by FindRepoRoot, anchored at the
+// repository's root even where that lies above root. Outside any repository
+// there are none.
+func infoExcludePatterns(root string) ([]SourcedPattern, error) {
+	repoRoot, err := FindRepoRoot(root)
+	if errors.Is(err, ErrNoRepo) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	dir, err := gitDir(repoRoot)
+	if err != nil || dir == "" {
+		return nil, err
+	}
+	sps, err := readOptionalPatterns(filepath.Join(dir, "info", "exclude"))
+	if err != nil {
+		return nil, err
+	}
+
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := filepath.Rel(repoRoot, absRoot)
+	if err != nil || rel == "." {
+		return sps, err
+	}
+	outer := strings.Split(filepath.ToSlash(rel), "/")
+	for _, sp := range sps {
+		sp.Pattern.(*pattern).outer = outer
+	}
+	return sps, nil
+}
+
+// readOptionalPatterns parses the ignore file at path as root-level patterns,
+// treating a missing file as an empty one.
+func readOptionalPatterns(path string) ([]SourcedPattern, error) {
+	sps, err := parseFilePatterns(path, nil)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return sps, err
+}
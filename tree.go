@@ -0,0 +1,51 @@
+package walkrepo
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Node is an entry in the tree built by BuildTree.
+type Node struct {
+	Name     string
+	IsDir    bool
+	Children []*Node // sorted by name; nil for files
+}
+
+// BuildTree walks root, returning the tree of its non-ignored entries. The
+// returned node represents root itself (or the repository root, with
+// WithDiscoverRoot), and each directory's children are sorted by name.
+// Options which change the form of reported paths, such as WithRelativeTo,
+// have no effect.
+func BuildTree(root string, opts ...Option) (*Node, error) {
+	root, opts, err := rootRelative(root, opts)
+	if err != nil {
+		return nil, err
+	}
+	tree := &Node{Name: filepath.Base(root), IsDir: true}
+	dirs := map[string]*Node{root: tree}
+
+	err = WalkRepo(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		node := &Node{Name: info.Name(), IsDir: info.IsDir()}
+		parent := dirs[filepath.Dir(path)]
+		parent.Children = append(parent.Children, node)
+		if node.IsDir {
+			dirs[path] = node
+		}
+		return nil
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, dir := range dirs {
+		sort.Slice(dir.Children, func(i, j int) bool {
+			return dir.Children[i].Name < dir.Children[j].Name
+		})
+	}
+	return tree, nil
+}
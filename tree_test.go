@@ -0,0 +1,103 @@
+package walkrepo
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// render draws a tree one node per line, indented by depth, with directories
+// suffixed by a slash.
+func render(n *Node) string {
+	var b strings.Builder
+	var draw func(n *Node, depth int)
+	draw = func(n *Node, depth int) {
+		suffix := ""
+		if n.IsDir {
+			suffix = "/"
+		}
+		fmt.Fprintf(&b, "%s%s%s\n", strings.Repeat("  ", depth), n.Name, suffix)
+		for _, child := range n.Children {
+			draw(child, depth+1)
+		}
+	}
+	draw(n, 0)
+	return b.String()
+}
+
+func TestBuildTree(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "project")
+	writeTree(t, root, map[string]string{
+		".gitignore":          "*.log\nnode_modules/\n",
+		"README.md":           "content",
+		"debug.log":           "content",
+		"src/main.go":         "content",
+		"src/util/strings.go": "content",
+		"src/util/trace.log":  "content",
+		"node_modules/x/y.js": "content",
+		"docs/.gitignore":     "drafts/",
+		"docs/guide.md":       "content",
+		"docs/drafts/wip.md":  "content",
+		"empty/.gitignore":    "*",
+	})
+
+	tree, err := BuildTree(root)
+	if err != nil {
+		t.Fatalf("BuildTree() error = %v", err)
+	}
+
+	want := `project/
+  README.md
+  docs/
+    guide.md
+  empty/
+  src/
+    main.go
+    util/
+      strings.go
+`
+	if got := render(tree); got != want {
+		t.Errorf("BuildTree() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestBuildTreePathOptions(t *testing.T) {
+	parent := t.TempDir()
+	root := filepath.Join(parent, "project")
+	writeTree(t, root, map[string]string{
+		".git/HEAD":     "ref: refs/heads/main",
+		".gitignore":    "*.log\n.git/\n",
+		"a.txt":         "content",
+		"sub/b.txt":     "content",
+		"sub/debug.log": "content",
+	})
+	chdir(t, parent)
+
+	want := `project/
+  a.txt
+  sub/
+    b.txt
+`
+	tests := []struct {
+		name string
+		root string
+		opts []Option
+	}{
+		{"relative to parent", root, []Option{WithRelativeTo(parent)}},
+		{"relative to subdirectory", root, []Option{WithRelativeTo(filepath.Join(root, "sub"))}},
+		{"absolute paths", "project", []Option{WithAbsolutePaths(true)}},
+		{"discover root", filepath.Join(root, "sub"), []Option{WithDiscoverRoot(true)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree, err := BuildTree(tt.root, tt.opts...)
+			if err != nil {
+				t.Fatalf("BuildTree() error = %v", err)
+			}
+			if got := render(tree); got != want {
+				t.Errorf("BuildTree() =\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package walkrepo
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+)
+
+// ProblemKind classifies the problems ValidateIgnoreFile finds.
+type ProblemKind int
+
+const (
+	// Redundant patterns repeat an earlier pattern, with no change in
+	// between to undo it, and so have no effect.
+	Redundant ProblemKind = iota
+	// NeverMatches patterns can match no path, such as those with "." or
+	// ".." components, malformed bracket expressions or a trailing
+	// backslash.
+	NeverMatches
+	// Conflicting patterns are undone by a later negation of the same
+	// pattern, or are negations which cannot take effect because a parent
+	// directory of what they would re-include is excluded.
+	Conflicting
+)
+
+func (k ProblemKind) String() string {
+	switch k {
+	case Redundant:
+		return "redundant"
+	case NeverMatches:
+		return "never matches"
+	case Conflicting:
+		return "conflicting"
+	}
+	return fmt.Sprintf("ProblemKind(%d)", int(k))
+}
+
+// Problem is a questionable pattern found by ValidateIgnoreFile.
+type Problem struct {
+	Line    int    // 1-based line number of the pattern
+	Text    string // the pattern, as written
+	Kind    ProblemKind
+	Message string // a description of the problem, naming related lines
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%d: %s: %s (%s)", p.Line, p.Text, p.Message, p.Kind)
+}
+
+// ValidateIgnoreFile reads the ignore file at path and reports its
+// questionable patterns, in line order: those which are redundant, those
+// which can never match, and those which conflict with others. Each pattern
+// is judged as git would apply it, within the file alone; a file with no
+// problems gives none. Only a failure to read the file is an error.
+func ValidateIgnoreFile(path string) ([]Problem, error) {
+	sps, err := parseFilePatterns(path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []Problem
+	report := func(sp SourcedPattern, kind ProblemKind, format string, args ...any) {
+		problems = append(problems, Problem{sp.Line, sp.Text, kind, fmt.Sprintf(format, args...)})
+	}
+
+	// The index of the most recent pattern of each form, ignoring negation.
+	last := make(map[string]int)
+	for i, sp := range sps {
+		p := sp.Pattern.(*pattern)
+		if reason := neverMatches(p); reason != "" {
+			report(sp, NeverMatches, "can never match: %s", reason)
+			continue
+		}
+
+		form := fmt.Sprintf("%t %t %s", p.anchored, p.dirOnly, strings.Join(p.pattern, "/"))
+		if j, ok := last[form]; ok {
+			prev := sps[j]
+			if prev.Pattern.(*pattern).inclusion != p.inclusion {
+				report(prev, Conflicting, "is undone by line %d", sp.Line)
+			} else if !opposes(p, sps[j+1:i]) {
+				report(sp, Redundant, "repeats line %d", prev.Line)
+			}
+		}
+		last[form] = i
+
+		if p.inclusion {
+			if dir, by := excludedParent(p, sps[:i]); by != nil {
+				report(sp, Conflicting, "cannot re-include within %s/, which line %d excludes", dir, by.Line)
+			}
+		}
+	}
+	sort.SliceStable(problems, func(i, j int) bool { return problems[i].Line < problems[j].Line })
+	return problems, nil
+}
+
+// opposes reports whether any of sps could undo p, being a negation where p
+// isn't or vice versa.
+func opposes(p *pattern, sps []SourcedPattern) bool {
+	for _, sp := range sps {
+		if sp.Pattern.(*pattern).inclusion != p.inclusion {
+			return true
+		}
+	}
+	return false
+}
+
+// neverMatches returns why p can match no path, or "" if it may match some.
+func neverMatches(p *pattern) string {
+	for _, glob := range p.pattern {
+		switch glob {
+		case ".", "..":
+			return fmt.Sprintf("no path has a %q component", glob)
+		case "":
+			return "no path has an empty component"
+		}
+		if _, err := filepath.Match(glob, ""); err != nil {
+			return "a malformed glob or trailing backslash"
+		}
+	}
+	return ""
+}
+
+// excludedParent returns the first of the parent directories of what the
+// negation p would re-include which the patterns before it exclude, along
+// with the pattern which excludes it. As git never descends into an excluded
+// directory, p can re-include nothing beneath it. Only parents spelled out
+// literally in p are considered.
+func excludedParent(p *pattern, before []SourcedPattern) (string, *SourcedPattern) {
+	if !p.anchored {
+		return "", nil
+	}
+	for n := 1; n < len(p.pattern); n++ {
+		name := p.pattern[n-1]
+		if name == "**" || strings.ContainsAny(name, `*?[\`) {
+			return "", nil
+		}
+		dir := p.pattern[:n]
+		for i := len(before) - 1; i >= 0; i-- {
+			if m := before[i].Match(dir, true); m == gitignore.Exclude {
+				return strings.Join(dir, "/"), &before[i]
+			} else if m == gitignore.Include {
+				break
+			}
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,76 @@
+package walkrepo
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestValidateIgnoreFile(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		".gitignore": `# build output
+*.log
+build/
+!build/keep.txt
+*.log
+!important.log
+*.log
+tmp
+!tmp
+./dist
+cache[
+foo\
+docs/
+docs/
+/vendor/
+!/vendor/
+/vendor/*
+!/vendor/mine/
+`,
+	})
+
+	problems, err := ValidateIgnoreFile(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		t.Fatalf("ValidateIgnoreFile() error = %v", err)
+	}
+	type summary struct {
+		Line int
+		Kind ProblemKind
+	}
+	var got []summary
+	for _, p := range problems {
+		got = append(got, summary{p.Line, p.Kind})
+	}
+	// The repeats of *.log aren't redundant, since negations come between
+	// them which they might undo.
+	want := []summary{
+		{4, Conflicting},   // !build/keep.txt, within build/
+		{8, Conflicting},   // tmp, undone by !tmp
+		{10, NeverMatches}, // ./dist
+		{11, NeverMatches}, // cache[
+		{12, NeverMatches}, // foo\
+		{14, Redundant},    // docs/ again
+		{15, Conflicting},  // /vendor/, undone by !/vendor/
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ValidateIgnoreFile() found:")
+		for _, p := range problems {
+			t.Errorf("  %s", p)
+		}
+		t.Errorf("want %v", want)
+	}
+	if problems[0].Message != "cannot re-include within build/, which line 3 excludes" {
+		t.Errorf("message = %q", problems[0].Message)
+	}
+
+	clean := filepath.Join(dir, "clean")
+	writeTree(t, dir, map[string]string{"clean": "*.log\n!keep.log\n/build/*\n!/build/keep/\n"})
+	if problems, err := ValidateIgnoreFile(clean); err != nil || len(problems) != 0 {
+		t.Errorf("ValidateIgnoreFile() of a clean file = %v, %v", problems, err)
+	}
+
+	if _, err := ValidateIgnoreFile(filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("ValidateIgnoreFile() of a missing file succeeded")
+	}
+}
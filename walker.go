@@ -0,0 +1,419 @@
+package walkrepo
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/format/gitattributes"
+)
+
+// Walker is a configured walk which can be reused across several walks,
+// possibly of different roots. It caches the parsed contents of ignore files
+// between walks, and accumulates statistics across them. A Walker is safe for
+// concurrent use.
+type Walker struct {
+	cfg   *config
+	cache *patternCache
+
+	mu    sync.Mutex
+	stats Stats
+}
+
+// Stats counts the entries visited by a Walker's walks.
+type Stats struct {
+	Dirs    int // directories passed to the walk function
+	Files   int // non-directories passed to the walk function
+	Ignored int // entries skipped by the ignore rules
+
+	// Evaluations counts the patterns evaluated in matching entries against
+	// the ignore rules. Large counts relative to the number of entries point
+	// to ignore files whose size is slowing the walk.
+	Evaluations int
+
+	// ContentCacheHits counts the ignore files whose patterns were reused
+	// from another file of identical content, with WithContentCache.
+	ContentCacheHits int
+
+	// MaxDepth is the depth of the deepest entry passed to the walk
+	// function, counted in path components beneath the root: 1 for the
+	// root's own entries, 2 for theirs, and so on. Across several walks, it
+	// is the greatest of any of them.
+	MaxDepth int
+}
+
+// NewWalker returns a Walker configured by opts.
+func NewWalker(opts ...Option) *Walker {
+	cfg := newConfig(opts)
+	return &Walker{
+		cfg:   cfg,
+		cache: newPatternCache(cfg.contentCache),
+	}
+}
+
+// Walk walks root as WalkRepo does, with the Walker's options.
+func (wk *Walker) Walk(root string, walkFn filepath.WalkFunc) error {
+	return wk.walk(root, "", walkFn, nil)
+}
+
+// WalkDir walks root as WalkRepoDir does, with the Walker's options.
+func (wk *Walker) WalkDir(root string, fn fs.WalkDirFunc) error {
+	return wk.walk(root, "", nil, fn)
+}
+
+// walk walks root, reporting entries to whichever of walkFn and walkDirFn
+// is set. If sub is not "", only the directory sub beneath root (given
+// relative to it) is walked, with the rules the walk of root would apply.
+func (wk *Walker) walk(root, sub string, walkFn filepath.WalkFunc, walkDirFn fs.WalkDirFunc) error {
+	cfg := wk.cfg
+	root, err := cfg.walkRoot(root)
+	if err != nil {
+		return err
+	}
+
+	w := &walker{
+		root:      root,
+		cfg:       cfg,
+		walkFn:    walkFn,
+		walkDirFn: walkDirFn,
+		ctx:       context.Background(),
+		cache:     wk.cache,
+	}
+	defer wk.addStats(&w.stats)
+
+	if w.cfg.relativeTo != "" {
+		if err := w.setReportPrefix(); err != nil {
+			return err
+		}
+	}
+	if w.cfg.timeout > 0 {
+		var cancel context.CancelFunc
+		w.ctx, cancel = context.WithTimeout(w.ctx, w.cfg.timeout)
+		defer cancel()
+	}
+
+	start, err := w.start()
+	if err != nil {
+		return err
+	}
+	if sub != "" {
+		var walked bool
+		start, walked, err = w.descend(start, sub)
+		if err != nil || !walked {
+			return err
+		}
+	}
+
+	if cfg.snapshotIgnores {
+		w.snapshot = make(map[string][]SourcedPattern)
+		if err := w.snapshotIgnores(start); err != nil {
+			return err
+		}
+	}
+
+	if cfg.traversal == BreadthFirst {
+		err = w.walkBreadthFirst(start)
+	} else {
+		w.realDirs = start.realDirs
+		err = w.walk(start.path, start.domain, start.patterns, start.attrs)
+	}
+	if err == filepath.SkipAll {
+		return nil
+	}
+	return err
+}
+
+// walkRoot returns the root from which a walk of root starts, and onto which
+// the paths it reports are joined (unless WithRelativeTo is set): the root of
+// the enclosing repository with WithDiscoverRoot, or root itself, made
+// absolute with WithAbsolutePaths.
+func (c *config) walkRoot(root string) (string, error) {
+	// Normalize the root to the OS-native form (on Windows, "C:/repo" to
+	// "C:\repo") up front, so that every path derived from it, and every
+	// filepath.Rel against it, agrees on its separators.
+	root = filepath.Clean(filepath.FromSlash(root))
+	if c.requireRepo && !c.discoverRoot {
+		if _, err := FindRepoRoot(root); err != nil {
+			return "", err
+		}
+	}
+	if c.discoverRoot {
+		repoRoot, err := FindRepoRoot(root)
+		if errors.Is(err, ErrNoRepo) && !c.requireRepo {
+			// Outside any repository, the root stands in for one.
+			repoRoot, err = filepath.Abs(root)
+		}
+		return repoRoot, err
+	}
+	if c.absolutePaths {
+		return filepath.Abs(root)
+	}
+	return root, nil
+}
+
+// rootRelative returns opts amended so that a walk of root reports paths
+// joined onto the root it starts from, whatever paths the caller's options
+// would have it report, along with that root. Helpers which report paths
+// relative to the root make them so against it.
+func rootRelative(root string, opts []Option) (string, []Option, error) {
+	opts = append(opts[:len(opts):len(opts)], func(c *config) {
+		c.relativeTo, c.absolutePaths = "", false
+	})
+	walkRoot, err := newConfig(opts).walkRoot(root)
+	return walkRoot, opts, err
+}
+
+// start returns the state with which a walk begins at the root.
+func (w *walker) start() (dirTask, error) {
+	start := dirTask{path: w.root, domain: append([]string{}, w.cfg.domainPrefix...)}
+	if w.cfg.followInternalSymlinks {
+		realRoot, err := realPath(w.root)
+		if err != nil {
+			return start, err
+		}
+		start.realDirs = []string{realRoot}
+	}
+
+	var err error
+	start.patterns, err = w.cfg.basePatterns(w.root)
+	if err != nil {
+		return start, err
+	}
+	if w.cfg.respectIndex {
+		w.tracked, err = loadTracked(w.root)
+	}
+	return start, err
+}
+
+// snapshotIgnores reads the ignore files of every directory a walk from
+// start would visit, into the walk's snapshot, so that the walk itself applies
+// them as they were before it began, whatever changes it meets along the way.
+func (w *walker) snapshotIgnores(start dirTask) error {
+	cfg := *w.cfg
+	cfg.dirExitHook, cfg.emptyDirHook, cfg.ignoredHook = nil, nil, nil
+	cfg.logger, cfg.traversal = nil, DepthFirst
+	pre := &walker{
+		root:      w.root,
+		cfg:       &cfg,
+		walkDirFn: func(string, fs.DirEntry, error) error { return nil },
+		ctx:       w.ctx,
+		cache:     w.cache,
+		realDirs:  start.realDirs,
+		snapshot:  w.snapshot,
+	}
+	return pre.walk(start.path, start.domain, start.patterns, start.attrs)
+}
+
+// descend makes its way from the directory of from down to the directory sub
+// beneath it, as a walk would, returning the state with which the walk would
+// reach sub. It reports false if the walk wouldn't reach sub, because it is
+// ignored or lies within an ignored directory.
+func (w *walker) descend(from dirTask, sub string) (dirTask, bool, error) {
+	task := from
+	for _, name := range strings.Split(sub, string(filepath.Separator)) {
+		files, err := w.cfg.listDir(task.path)
+		if err != nil {
+			return task, false, err
+		}
+		task.patterns, task.attrs, err = w.localRules(task.path, task.domain, files, task.patterns, task.attrs)
+		if err != nil {
+			return task, false, err
+		}
+
+		path := filepath.Join(task.path, name)
+		var entry fs.DirEntry
+		for _, file := range files {
+			if file.Name() == name {
+				entry = file
+				break
+			}
+		}
+		realPath := ""
+		if entry != nil && task.realDirs != nil {
+			realPath = filepath.Join(task.realDirs[len(task.realDirs)-1], name)
+			if entry.Type()&fs.ModeSymlink != 0 {
+				w.realDirs = task.realDirs
+				if target, d, ok := w.followLink(path); ok {
+					realPath, entry = target, d
+				}
+			}
+		}
+		if entry == nil {
+			return task, false, &fs.PathError{Op: "walk", Path: path, Err: fs.ErrNotExist}
+		} else if !entry.IsDir() {
+			return task, false, &fs.PathError{Op: "walk", Path: path, Err: syscall.ENOTDIR}
+		}
+
+		if (w.cfg.skipGit && name == ".git") || w.cfg.isIgnoreFile(name) {
+			return task, false, nil
+		}
+		var attrMatcher gitattributes.Matcher
+		if len(task.attrs) > 0 {
+			attrMatcher = gitattributes.NewMatcher(task.attrs)
+		}
+		domain := append(task.domain[:len(task.domain):len(task.domain)], name)
+		_, ignored := w.decide(domain, true, task.patterns, attrMatcher)
+		if w.tracked != nil {
+			dir := strings.Join(task.domain[len(w.cfg.domainPrefix):], "/")
+			ignored = w.tracked.ignored(dir, name, true, ignored)
+		}
+		if ignored {
+			return task, false, nil
+		}
+		if descend, err := w.descends(path, entry); err != nil || !descend {
+			return task, false, err
+		}
+
+		task.path, task.domain = path, domain
+		if task.realDirs != nil {
+			task.realDirs = append(task.realDirs[:len(task.realDirs):len(task.realDirs)], realPath)
+		}
+	}
+	return task, true, nil
+}
+
+// Stats returns the statistics accumulated by the Walker's walks since it was
+// created or last Reset.
+func (wk *Walker) Stats() Stats {
+	wk.mu.Lock()
+	stats := wk.stats
+	wk.mu.Unlock()
+	stats.ContentCacheHits = wk.cache.contentHits()
+	return stats
+}
+
+// Reset clears the Walker's cached ignore files and accumulated statistics,
+// so that later walks are independent of earlier ones.
+func (wk *Walker) Reset() {
+	wk.cache.reset()
+
+	wk.mu.Lock()
+	defer wk.mu.Unlock()
+	wk.stats = Stats{}
+}
+
+func (wk *Walker) addStats(s *Stats) {
+	wk.mu.Lock()
+	defer wk.mu.Unlock()
+	wk.stats.Dirs += s.Dirs
+	wk.stats.Files += s.Files
+	wk.stats.Ignored += s.Ignored
+	wk.stats.Evaluations += s.Evaluations
+	if s.MaxDepth > wk.stats.MaxDepth {
+		wk.stats.MaxDepth = s.MaxDepth
+	}
+}
+
+// patternCache holds the parsed patterns of ignore files, for reuse for as
+// long as the files' sizes and modification times are unchanged. A nil
+// patternCache caches nothing.
+//
+// When caching by content, files which have changed (or which were never
+// seen) are read and hashed, and reuse the patterns of any file of identical
+// content, rebased onto their own domains.
+type patternCache struct {
+	mu      sync.Mutex
+	entries map[patternCacheKey]cachedPatterns
+
+	byContent map[[sha256.Size]byte][]SourcedPattern // nil unless caching by content
+	hits      int
+}
+
+// patternCacheKey identifies a parsed ignore file. The domain is part of the
+// key since the same file is parsed differently beneath different roots.
+type patternCacheKey struct {
+	path   string
+	domain string
+}
+
+type cachedPatterns struct {
+	size     int64
+	modTime  time.Time
+	patterns []SourcedPattern
+}
+
+func newPatternCache(byContent bool) *patternCache {
+	c := &patternCache{entries: make(map[patternCacheKey]cachedPatterns)}
+	if byContent {
+		c.byContent = make(map[[sha256.Size]byte][]SourcedPattern)
+	}
+	return c
+}
+
+// parse returns the patterns of the ignore file at path, whose directory
+// listing gave info, parsing it only if it isn't already cached.
+func (c *patternCache) parse(path string, domain []string, info os.FileInfo) ([]SourcedPattern, error) {
+	if c == nil {
+		return parseFilePatterns(path, domain)
+	}
+
+	key := patternCacheKey{path, filepath.Join(domain...)}
+	c.mu.Lock()
+	cached, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && cached.size == info.Size() && cached.modTime.Equal(info.ModTime()) {
+		return cached.patterns, nil
+	}
+
+	var patterns []SourcedPattern
+	var err error
+	if c.byContent != nil {
+		patterns, err = c.parseByContent(path, domain)
+	} else {
+		patterns, err = parseFilePatterns(path, domain)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.entries[key] = cachedPatterns{info.Size(), info.ModTime(), patterns}
+	c.mu.Unlock()
+	return patterns, nil
+}
+
+// parseByContent returns the patterns of the ignore file at path, reusing
+// those of any file with the same content.
+func (c *patternCache) parseByContent(path string, domain []string) ([]SourcedPattern, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(content)
+
+	c.mu.Lock()
+	patterns, ok := c.byContent[sum]
+	if ok {
+		c.hits++
+	}
+	c.mu.Unlock()
+	if !ok {
+		patterns = parsePatterns(string(content), "", nil)
+		c.mu.Lock()
+		c.byContent[sum] = patterns
+		c.mu.Unlock()
+	}
+	return rebasePatterns(patterns, path, domain), nil
+}
+
+func (c *patternCache) contentHits() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits
+}
+
+func (c *patternCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[patternCacheKey]cachedPatterns)
+	if c.byContent != nil {
+		c.byContent = make(map[[sha256.Size]byte][]SourcedPattern)
+	}
+	c.hits = 0
+}
@@ -0,0 +1,375 @@
+package walkrepo
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWalkerReuse(t *testing.T) {
+	first := t.TempDir()
+	writeTree(t, first, map[string]string{
+		".gitignore": "*.log",
+		"a.txt":      "content",
+		"a.log":      "content",
+		"sub/b.txt":  "content",
+	})
+	second := t.TempDir()
+	writeTree(t, second, map[string]string{
+		".gitignore": "*.txt",
+		"c.txt":      "content",
+		"c.log":      "content",
+	})
+
+	walk := func(t *testing.T, wk *Walker, root string) map[string]bool {
+		t.Helper()
+		walked := make(map[string]bool)
+		err := wk.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, _ := filepath.Rel(root, path)
+			walked[filepath.ToSlash(rel)] = true
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Walk() error = %v", err)
+		}
+		return walked
+	}
+
+	wk := NewWalker()
+	assertWalked(t, walk(t, wk, first), []string{"a.txt", "sub", "sub/b.txt"}, []string{"a.log"})
+	if got, want := wk.Stats(), (Stats{Dirs: 1, Files: 2, Ignored: 1, Evaluations: 4, MaxDepth: 2}); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+
+	t.Run("without reset", func(t *testing.T) {
+		assertWalked(t, walk(t, wk, second), []string{"c.log"}, []string{"c.txt", "a.txt"})
+		if got, want := wk.Stats(), (Stats{Dirs: 1, Files: 3, Ignored: 2, Evaluations: 6, MaxDepth: 2}); got != want {
+			t.Errorf("Stats() = %+v, want accumulated %+v", got, want)
+		}
+	})
+
+	t.Run("with reset", func(t *testing.T) {
+		wk.Reset()
+		if got := wk.Stats(); got != (Stats{}) {
+			t.Errorf("Stats() after Reset() = %+v, want zero", got)
+		}
+		assertWalked(t, walk(t, wk, second), []string{"c.log"}, []string{"c.txt"})
+		if got, want := wk.Stats(), (Stats{Files: 1, Ignored: 1, Evaluations: 2, MaxDepth: 1}); got != want {
+			t.Errorf("Stats() = %+v, want %+v", got, want)
+		}
+	})
+}
+
+func TestWalkerResetClearsCache(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore": "*.log",
+		"a.log":      "content",
+		"a.tmp":      "content",
+	})
+	ignoreFile := filepath.Join(root, ".gitignore")
+	info, err := os.Stat(ignoreFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wk := NewWalker()
+	walked := make(map[string]bool)
+	walkFn := func(path string, info os.FileInfo, err error) error {
+		walked[filepath.Base(path)] = true
+		return err
+	}
+	if err := wk.Walk(root, walkFn); err != nil {
+		t.Fatal(err)
+	}
+	assertWalked(t, walked, []string{"a.tmp"}, []string{"a.log"})
+
+	// Rewrite the ignore file with content of the same size, and restore its
+	// modification time, so that the cached patterns appear current.
+	writeTree(t, root, map[string]string{".gitignore": "*.tmp"})
+	mtime := info.ModTime().Add(-time.Second)
+	if err := os.Chtimes(ignoreFile, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	walked = make(map[string]bool)
+	if err := wk.Walk(root, walkFn); err != nil {
+		t.Fatal(err)
+	}
+	// The first walk's stale modification time doesn't match, so the file
+	// is re-read.
+	assertWalked(t, walked, []string{"a.log"}, []string{"a.tmp"})
+
+	// Once cached with the new content, a changed file with identical size
+	// and modification time is only noticed after a Reset.
+	writeTree(t, root, map[string]string{".gitignore": "*.log"})
+	if err := os.Chtimes(ignoreFile, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	walked = make(map[string]bool)
+	if err := wk.Walk(root, walkFn); err != nil {
+		t.Fatal(err)
+	}
+	assertWalked(t, walked, []string{"a.log"}, []string{"a.tmp"})
+
+	wk.Reset()
+	walked = make(map[string]bool)
+	if err := wk.Walk(root, walkFn); err != nil {
+		t.Fatal(err)
+	}
+	assertWalked(t, walked, []string{"a.tmp"}, []string{"a.log"})
+}
+
+func TestWalkerStatsEvaluations(t *testing.T) {
+	const patterns = 500
+	var ignore strings.Builder
+	for i := 0; i < patterns; i++ {
+		fmt.Fprintf(&ignore, "unused-%d\n", i)
+	}
+
+	files := map[string]string{".gitignore": ignore.String()}
+	for i := 0; i < 10; i++ {
+		files[fmt.Sprintf("f%d.txt", i)] = "content"
+	}
+	root := t.TempDir()
+	writeTree(t, root, files)
+
+	wk := NewWalker()
+	if err := wk.Walk(root, func(path string, info os.FileInfo, err error) error { return err }); err != nil {
+		t.Fatal(err)
+	}
+	// No pattern matches, so every pattern is evaluated for every entry.
+	stats := wk.Stats()
+	if want := patterns * stats.Files; stats.Evaluations != want {
+		t.Errorf("Evaluations = %d for %d files, want %d", stats.Evaluations, stats.Files, want)
+	}
+
+	// A small ignore file costs proportionally less.
+	writeTree(t, root, map[string]string{".gitignore": "unused\n"})
+	wk.Reset()
+	if err := wk.Walk(root, func(path string, info os.FileInfo, err error) error { return err }); err != nil {
+		t.Fatal(err)
+	}
+	if got := wk.Stats().Evaluations; got != stats.Files {
+		t.Errorf("Evaluations = %d with one pattern, want %d", got, stats.Files)
+	}
+}
+
+func TestWalkerStatsMaxDepth(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":          "build/\n",
+		"a.txt":               "content",
+		"one/two/three/b.txt": "content",
+		"one/c.txt":           "content",
+		"build/1/2/3/4/5/d":   "content",
+	})
+	if err := os.Mkdir(filepath.Join(root, "one", "two", "three", "four"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// The empty directory one/two/three/four is the deepest entry walked;
+	// build's deeper contents are ignored.
+	for _, opts := range [][]Option{
+		nil,
+		{WithTraversal(BreadthFirst)},
+		{WithDomainPrefix([]string{"mnt", "repo"})},
+	} {
+		wk := NewWalker(opts...)
+		if err := wk.WalkDir(root, func(path string, d fs.DirEntry, err error) error { return err }); err != nil {
+			t.Fatal(err)
+		}
+		if got := wk.Stats().MaxDepth; got != 4 {
+			t.Errorf("MaxDepth = %d, want 4", got)
+		}
+	}
+
+	wk := NewWalker(WithSimpleExcludes("two"))
+	if err := wk.Walk(root, func(path string, info os.FileInfo, err error) error { return err }); err != nil {
+		t.Fatal(err)
+	}
+	if got := wk.Stats().MaxDepth; got != 2 {
+		t.Errorf("MaxDepth = %d without one/two, want 2", got)
+	}
+}
+
+func TestWithContentCache(t *testing.T) {
+	root := t.TempDir()
+	boilerplate := "*.log\n/local\n"
+	writeTree(t, root, map[string]string{
+		"a/.gitignore":  boilerplate,
+		"a/x.log":       "content",
+		"a/local/f":     "content",
+		"a/sub/local/f": "content",
+		"b/.gitignore":  boilerplate,
+		"b/y.log":       "content",
+		"b/local/f":     "content",
+		"b/keep.txt":    "content",
+		"c/.gitignore":  "*.txt\n",
+		"c/z.log":       "content",
+	})
+
+	wk := NewWalker(WithContentCache(true))
+	walked := make(map[string]bool)
+	walkFn := func(path string, info os.FileInfo, err error) error {
+		rel, _ := filepath.Rel(root, path)
+		walked[filepath.ToSlash(rel)] = true
+		return err
+	}
+	if err := wk.Walk(root, walkFn); err != nil {
+		t.Fatal(err)
+	}
+	// The shared patterns are rooted at each file's own directory.
+	assertWalked(t, walked,
+		[]string{"a/sub/local/f", "b/keep.txt", "c/z.log"},
+		[]string{"a/x.log", "a/local", "b/y.log", "b/local"},
+	)
+	// Of the two identical files, the second reused the first's patterns.
+	if got := wk.Stats().ContentCacheHits; got != 1 {
+		t.Errorf("ContentCacheHits = %d, want 1", got)
+	}
+
+	// Files which are touched but unchanged are re-read, but not re-parsed.
+	later := time.Now().Add(time.Hour)
+	for _, dir := range []string{"a", "b", "c"} {
+		if err := os.Chtimes(filepath.Join(root, dir, ".gitignore"), later, later); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := wk.Walk(root, walkFn); err != nil {
+		t.Fatal(err)
+	}
+	if got := wk.Stats().ContentCacheHits; got != 4 {
+		t.Errorf("ContentCacheHits = %d after touching the files, want 4", got)
+	}
+
+	// Without the option, nothing is shared.
+	wk = NewWalker()
+	if err := wk.Walk(root, walkFn); err != nil {
+		t.Fatal(err)
+	}
+	if got := wk.Stats().ContentCacheHits; got != 0 {
+		t.Errorf("ContentCacheHits = %d without WithContentCache, want 0", got)
+	}
+}
+
+// TestWalkerConcurrentModification runs walks concurrently, sharing Walkers
+// and so their caches, over a tree which a further goroutine is changing
+// beneath them. Run with -race, it checks that the walks share no state
+// unsafely; either way, entries appearing and vanishing mid-walk, and
+// directories replaced by files, must not fail the walks or disturb the
+// entries which aren't changing.
+func TestWalkerConcurrentModification(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":    "*.log\n",
+		"stable/a.txt":  "content",
+		"stable/b.log":  "content",
+		"churn/.keep":   "",
+		"churn/flip/.x": "",
+	})
+
+	stop := make(chan struct{})
+	churned := make(chan struct{})
+	go func() {
+		defer close(churned)
+		// Failures, as on Windows where a directory being listed can't be
+		// removed, only lessen the churn.
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			dir := filepath.Join(root, "churn", fmt.Sprintf("d%d", i%4))
+			os.MkdirAll(filepath.Join(dir, "sub", "deeper"), 0755)
+			os.WriteFile(filepath.Join(dir, ".gitignore"), []byte(fmt.Sprintf("*.tmp\n!keep%d.tmp\n", i)), 0644)
+			os.WriteFile(filepath.Join(dir, "sub", "f.txt"), []byte("content"), 0644)
+			os.WriteFile(filepath.Join(dir, "sub", "g.tmp"), []byte("content"), 0644)
+			os.RemoveAll(dir)
+
+			flip := filepath.Join(root, "churn", "flip")
+			os.RemoveAll(flip)
+			if i%2 == 0 {
+				os.WriteFile(flip, []byte("content"), 0644)
+			} else {
+				os.MkdirAll(filepath.Join(flip, "inner"), 0755)
+			}
+		}
+	}()
+
+	walkers := []*Walker{
+		NewWalker(),
+		NewWalker(WithContentCache(true)),
+		NewWalker(WithTraversal(BreadthFirst)),
+	}
+	var wg sync.WaitGroup
+	for _, wk := range walkers {
+		for g := 0; g < 3; g++ {
+			wg.Add(1)
+			go func(wk *Walker) {
+				defer wg.Done()
+				for n := 0; n < 20; n++ {
+					walked := make(map[string]bool)
+					err := wk.Walk(root, func(path string, info os.FileInfo, err error) error {
+						if err != nil {
+							return err
+						}
+						rel, _ := filepath.Rel(root, path)
+						walked[filepath.ToSlash(rel)] = true
+						return nil
+					})
+					if err != nil {
+						t.Errorf("Walk() error = %v", err)
+						return
+					}
+					if !walked["stable/a.txt"] || walked["stable/b.log"] {
+						t.Errorf("Walk() disturbed the unchanging entries: stable/a.txt %v, stable/b.log %v",
+							walked["stable/a.txt"], walked["stable/b.log"])
+						return
+					}
+				}
+			}(wk)
+		}
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for n := 0; n < 20; n++ {
+			var mu sync.Mutex
+			var stable bool
+			err := WalkRepoPool(root, 4, func(path string, info os.FileInfo) error {
+				mu.Lock()
+				defer mu.Unlock()
+				stable = stable || path == filepath.Join(root, "stable", "a.txt")
+				return nil
+			})
+			if err != nil {
+				t.Errorf("WalkRepoPool() error = %v", err)
+				return
+			}
+			if !stable {
+				t.Errorf("WalkRepoPool() passed over stable/a.txt")
+				return
+			}
+		}
+	}()
+
+	wg.Wait()
+	close(stop)
+	<-churned
+
+	for _, wk := range walkers {
+		if stats := wk.Stats(); stats.Files < 3*20 {
+			t.Errorf("Stats() = %+v, want at least a file per walk", stats)
+		}
+	}
+}
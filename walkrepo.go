@@ -1,112 +1,676 @@
 package walkrepo
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"syscall"
+	"time"
 
+	"github.com/go-git/go-git/v5/plumbing/format/gitattributes"
 	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
 )
 
 // walkRepo walks through the repository directory, applying .gitignore rules.
-func WalkRepo(root string, walkFn filepath.WalkFunc) error {
-	var ps []gitignore.Pattern
-	domain := []string{}
+//
+// As in git, each entry is decided by the last pattern to match it, with the
+// patterns of a directory's ignore files following those of its ancestors',
+// and each file's patterns in file order. So a pattern repeated is as good as
+// one, and of a pattern and its negation, whichever comes later wins, be it in
+// the same file or a deeper one.
+//
+// As with filepath.Walk, walkFn returning filepath.SkipDir for a directory
+// skips its contents, and for a file skips the rest of the file's directory;
+// returning filepath.SkipAll ends the walk, without error.
+//
+// A root which is a symlink to a directory is walked as that directory, with
+// paths reported beneath the symlink's own path rather than its target's.
+// WithResolvePaths gives the resolved paths as well.
+func WalkRepo(root string, walkFn filepath.WalkFunc, opts ...Option) error {
+	return NewWalker(opts...).Walk(root, walkFn)
+}
 
-	var walk func(string, []string, []gitignore.Pattern) error
+// WalkRepoDir is WalkRepo for an fs.WalkDirFunc, as taken by fs.WalkDir. The
+// entries are passed as the fs.DirEntry values listed from their directories,
+// so no entry is stat'ed unless fn calls its Info method. As with WalkRepo,
+// the root itself is not passed to fn, nor is fn ever passed an error.
+func WalkRepoDir(root string, fn fs.WalkDirFunc, opts ...Option) error {
+	return NewWalker(opts...).WalkDir(root, fn)
+}
 
-	walk = func(path string, domain []string, patterns []gitignore.Pattern) error {
-		f, err := os.Open(path)
-		if err != nil {
+// WalkSubtree walks only the directory subPath beneath repoRoot, given
+// relative to repoRoot or as an absolute path, applying the rules a walk of
+// repoRoot would: those of every ignore file from repoRoot's down to
+// subPath's, and those contributed by options. Only entries beneath subPath
+// are passed to walkFn, with paths joined onto repoRoot as in WalkRepo; the
+// subtree's root itself is not.
+//
+// If subPath is ignored, or lies within an ignored directory, nothing is
+// walked. If it doesn't exist or isn't a directory, an error is returned.
+func WalkSubtree(repoRoot, subPath string, walkFn filepath.WalkFunc, opts ...Option) error {
+	sub, err := subdir(repoRoot, subPath)
+	if err != nil {
+		return err
+	}
+	return NewWalker(opts...).walk(repoRoot, sub, walkFn, nil)
+}
+
+// WalkRepoWithIgnoreFile is WalkRepo with the patterns of the ignore file at
+// ignoreFilePath, which may lie outside the tree, applied as root-level rules
+// beneath those of the tree's own .gitignore files. It suits shared ignore
+// files kept outside of the repositories they apply to.
+func WalkRepoWithIgnoreFile(root, ignoreFilePath string, walkFn filepath.WalkFunc, opts ...Option) error {
+	opts = append(opts[:len(opts):len(opts)], func(c *config) {
+		c.baseFiles = append(c.baseFiles, ignoreFilePath)
+	})
+	return WalkRepo(root, walkFn, opts...)
+}
+
+// WalkWithBasePatterns is WalkRepo with the already-parsed patterns base
+// applied as root-level rules, beneath those of the tree's own .gitignore
+// files, as WalkRepoWithIgnoreFile applies a file's. Parsing a shared ignore
+// file once, with ReadPatterns and a nil domain, lets its patterns be reused
+// across the walks of many repositories. Patterns which are SourcedPatterns
+// keep their sources; others are applied as they are, without one.
+func WalkWithBasePatterns(root string, base []gitignore.Pattern, walkFn filepath.WalkFunc, opts ...Option) error {
+	opts = append(opts[:len(opts):len(opts)], func(c *config) {
+		c.seedPatterns = append(c.seedPatterns, base...)
+	})
+	return WalkRepo(root, walkFn, opts...)
+}
+
+// walker holds the state of a single call to WalkRepo or WalkRepoDir. Only
+// one of walkFn and walkDirFn is set.
+type walker struct {
+	root      string
+	cfg       *config
+	walkFn    filepath.WalkFunc
+	walkDirFn fs.WalkDirFunc
+	ctx       context.Context
+
+	// reportPrefix, when set, replaces the root in reported paths.
+	reportPrefix string
+
+	cache *patternCache
+	stats Stats
+
+	// realDirs holds the symlink-resolved paths of the directories being
+	// walked, from the root down, when following internal symlinks.
+	realDirs []string
+
+	// queue holds the directories yet to be walked, in a breadth-first walk.
+	queue []dirTask
+
+	// snapshot, when set, holds the patterns of the ignore files of each
+	// directory, by path, as they were first read.
+	snapshot map[string][]SourcedPattern
+
+	// tracked, with WithRespectIndex, holds the files git's index tracks.
+	tracked *trackedPaths
+}
+
+// dirTask is a directory awaiting its walk, along with the state its walk
+// inherits from its parent.
+type dirTask struct {
+	path     string
+	domain   []string
+	patterns []SourcedPattern
+	attrs    []gitattributes.MatchAttribute
+	realDirs []string
+}
+
+// walkBreadthFirst walks the directory of start and then, level by level,
+// the directories beneath it, each level in the order it was discovered.
+func (w *walker) walkBreadthFirst(start dirTask) error {
+	w.queue = []dirTask{start}
+	for len(w.queue) > 0 {
+		task := w.queue[0]
+		w.queue = w.queue[1:]
+		w.realDirs = task.realDirs
+		if err := w.walk(task.path, task.domain, task.patterns, task.attrs); err != nil {
 			return err
 		}
-		defer f.Close()
+	}
+	return nil
+}
 
-		files, err := f.Readdir(-1)
-		if err != nil {
+// realPath returns the absolute, symlink-resolved form of path.
+func realPath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(abs)
+}
+
+// followLink resolves the symlink at path, for WithFollowInternalSymlinks.
+// It returns the link's resolved target and a DirEntry describing the target,
+// or false if the link is not to be followed: because it dangles, points
+// outside the root, or would lead the walk around a cycle.
+func (w *walker) followLink(path string) (string, fs.DirEntry, bool) {
+	target, err := realPath(path)
+	if err != nil {
+		return "", nil, false
+	}
+	rel, err := filepath.Rel(w.realDirs[0], target)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", nil, false
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", nil, false
+	}
+	if info.IsDir() {
+		for _, dir := range w.realDirs {
+			if dir == target {
+				return "", nil, false
+			}
+		}
+	}
+	return target, fs.FileInfoToDirEntry(info), true
+}
+
+// setReportPrefix arranges for reported paths to be relative to the base
+// given to WithRelativeTo, falling back to absolute paths (or failing, with
+// WithStrictRelativeTo) when they cannot be.
+func (w *walker) setReportPrefix() error {
+	absRoot, err := filepath.Abs(w.root)
+	if err != nil {
+		return err
+	}
+	absBase, err := filepath.Abs(w.cfg.relativeTo)
+	if err != nil {
+		return err
+	}
+	w.reportPrefix, err = filepath.Rel(absBase, absRoot)
+	if err != nil {
+		if w.cfg.strictRelativeTo {
+			return fmt.Errorf("walkrepo: cannot report paths relative to %s: %w", w.cfg.relativeTo, err)
+		}
+		w.reportPrefix = absRoot
+	}
+	return nil
+}
+
+// reportPath returns the form of path given to the caller.
+func (w *walker) reportPath(path string) (string, error) {
+	if w.reportPrefix == "" {
+		return path, nil
+	}
+	rel, err := filepath.Rel(w.root, path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(w.reportPrefix, rel), nil
+}
+
+// emit passes the entry at path, depth components beneath the root, to the
+// walk function. Only a WalkFunc needs the entry's FileInfo; if it can't be
+// had, as when the entry has vanished since its directory was listed, the
+// error is handled as handleError decides.
+func (w *walker) emit(path string, d fs.DirEntry, depth int) error {
+	reported, err := w.reportPath(path)
+	if err != nil {
+		return err
+	}
+	if w.walkDirFn != nil {
+		w.count(d, depth)
+		return w.walkDirFn(reported, d, nil)
+	}
+
+	info, err := d.Info()
+	if err != nil {
+		if err := w.handleError(path, err); err != nil {
 			return err
 		}
+		return errSkipEntry
+	}
+	w.count(d, depth)
+	return w.walkFn(reported, info, nil)
+}
 
-		// First, check for .gitignore in this directory and process it
-		localPatterns := make([]gitignore.Pattern, len(patterns))
-		copy(localPatterns, patterns)
+// errSkipEntry is returned by emit for an entry which is to be passed over,
+// neither reported nor descended into.
+var errSkipEntry = errors.New("walkrepo: entry skipped")
 
-		for _, file := range files {
-			if file.Name() == ".gitignore" {
-				filePath := filepath.Join(path, file.Name())
-				filePatterns, err := parseFilePatterns(filePath, domain)
-				if err != nil {
+// count adds the entry d, about to be reported from depth components beneath
+// the root, to the walk's Stats.
+func (w *walker) count(d fs.DirEntry, depth int) {
+	if depth > w.stats.MaxDepth {
+		w.stats.MaxDepth = depth
+	}
+	if d.IsDir() {
+		w.stats.Dirs++
+	} else {
+		w.stats.Files++
+	}
+}
+
+// ErrTimeout is returned (wrapping context.DeadlineExceeded) when a walk
+// exceeds the duration given to WithTimeout.
+var ErrTimeout = errors.New("walkrepo: walk timed out")
+
+// expired returns ErrTimeout once the walk's deadline has passed.
+func (w *walker) expired() error {
+	if w.ctx.Err() != nil {
+		return fmt.Errorf("%w after %s: %w", ErrTimeout, w.cfg.timeout, w.ctx.Err())
+	}
+	return nil
+}
+
+func (w *walker) walk(path string, domain []string, patterns []SourcedPattern, attrs []gitattributes.MatchAttribute) error {
+	if err := w.expired(); err != nil {
+		return err
+	}
+	if w.cfg.logger != nil {
+		w.cfg.logger.Debug("walkrepo: entering directory", "path", path)
+	}
+	files, err := w.cfg.listDir(path)
+	if err != nil {
+		return w.handleError(path, err)
+	}
+	if w.cfg.dirSort {
+		sort.Slice(files, func(i, j int) bool { return files[i].Name() < files[j].Name() })
+	}
+
+	// First, check for .gitignore in this directory and process it.
+	// An ignore file which can't be read leaves the directory's rules
+	// unknown, so the directory is walked only if it is readable, or is
+	// skipped if the error handler allows it.
+	localPatterns, localAttrs, err := w.localRules(path, domain, files, patterns, attrs)
+	if err != nil {
+		return w.handleError(path, err)
+	}
+	var attrMatcher gitattributes.Matcher
+	if len(localAttrs) > 0 {
+		attrMatcher = gitattributes.NewMatcher(localAttrs)
+	}
+
+	// The number of entries passed to the walk function, for
+	// WithEmptyDirCallback.
+	reported := 0
+
+	// Scratch space for the path components of each entry, which share
+	// the directory's domain as a prefix.
+	pathComponents := make([]string, len(domain)+1)
+	copy(pathComponents, domain)
+	depth := len(domain) - len(w.cfg.domainPrefix) + 1
+	var dirKey string
+	if w.tracked != nil {
+		dirKey = strings.Join(domain[len(w.cfg.domainPrefix):], "/")
+	}
+
+	// Then process all other files
+	for _, file := range files {
+		if err := w.expired(); err != nil {
+			return err
+		}
+		// Symlinks being followed may prove to be directories, and are
+		// judged by extension once followed.
+		if w.cfg.excludesExtension(file) && (w.realDirs == nil || file.Type()&fs.ModeSymlink == 0) {
+			continue
+		}
+		filePath := filepath.Join(path, file.Name())
+
+		if w.cfg.skipGit && file.Name() == ".git" {
+			continue
+		}
+
+		if w.cfg.isIgnoreFile(file.Name()) {
+			// Ignore files have already been parsed for their rules, and are
+			// only reported on request.
+			if w.cfg.reportIgnoreFiles {
+				err := w.emit(filePath, file, depth)
+				if err != errSkipEntry {
+					reported++
+				}
+				if err == filepath.SkipDir {
+					break
+				} else if err != nil && err != errSkipEntry {
 					return err
 				}
-				localPatterns = append(localPatterns, filePatterns...)
 			}
+			continue
 		}
-		matcher := gitignore.NewMatcher(localPatterns)
 
-		// Then process all other files
-		for _, file := range files {
-			if file.Name() == ".gitignore" {
-				continue
+		// Symlinks which stay within the root are followed on request, and
+		// reported as their targets.
+		realFilePath := ""
+		if w.realDirs != nil {
+			realFilePath = filepath.Join(w.realDirs[len(w.realDirs)-1], file.Name())
+			if file.Type()&fs.ModeSymlink != 0 {
+				target, d, ok := w.followLink(filePath)
+				if ok {
+					realFilePath, file = target, d
+				}
+				if w.cfg.excludesExtension(file) {
+					continue
+				}
+			}
+		}
+
+		// Relative path components for matching are taken from the domain
+		// rather than filepath.Rel, so no root (eg, "/") can yield ".."
+		pathComponents[len(domain)] = file.Name()
+		decider, isIgnored := w.decide(pathComponents, file.IsDir(), localPatterns, attrMatcher)
+		if w.tracked != nil {
+			if ignored := w.tracked.ignored(dirKey, file.Name(), file.IsDir(), isIgnored); ignored != isIgnored {
+				decider, isIgnored = "tracked", ignored
+			}
+		}
+		if isIgnored {
+			w.stats.Ignored++
+			if w.cfg.ignoredHook != nil {
+				if err := w.cfg.ignoredHook(filePath, file); err != nil {
+					return err
+				}
 			}
+		}
+		if w.cfg.logger != nil {
+			w.cfg.logger.Debug("walkrepo: ignore decision", "path", filePath, "ignored", isIgnored, "pattern", decider)
+		}
 
-			filePath := filepath.Join(path, file.Name())
-			// Get relative path components for matching
-			relPath, err := filepath.Rel(root, filePath)
+		if !isIgnored {
+			err := w.emit(filePath, file, depth)
+			if err != errSkipEntry {
+				reported++
+			}
 			if err != nil {
+				if err == errSkipEntry || (err == filepath.SkipDir && file.IsDir()) {
+					continue
+				}
+				if err == filepath.SkipDir {
+					// Returned for a file, as in filepath.Walk, it skips the
+					// rest of the directory.
+					break
+				}
 				return err
 			}
-			pathComponents := strings.Split(relPath, string(filepath.Separator))
-			isIgnored := matcher.Match(pathComponents, file.IsDir())
 
-			if !isIgnored {
-				err := walkFn(filePath, file, nil)
-				if err != nil {
-					if err == filepath.SkipDir && file.IsDir() {
-						continue
-					}
+			if file.IsDir() {
+				if descend, err := w.descends(filePath, file); err != nil {
 					return err
+				} else if !descend {
+					continue
 				}
 
-				if file.IsDir() {
-					newDomain := append(domain, file.Name())
-					err := walk(filePath, newDomain, localPatterns)
-					if err != nil {
-						return err
+				// Cap the domain so that siblings never share (and overwrite)
+				// the backing array of each other's domains.
+				newDomain := append(domain[:len(domain):len(domain)], file.Name())
+				if w.cfg.traversal == BreadthFirst {
+					var realDirs []string
+					if w.realDirs != nil {
+						realDirs = append(w.realDirs[:len(w.realDirs):len(w.realDirs)], realFilePath)
 					}
+					w.queue = append(w.queue, dirTask{filePath, newDomain, localPatterns, localAttrs, realDirs})
+					continue
+				}
+				if w.realDirs != nil {
+					w.realDirs = append(w.realDirs, realFilePath)
+				}
+				err := w.walk(filePath, newDomain, localPatterns, localAttrs)
+				if w.realDirs != nil {
+					w.realDirs = w.realDirs[:len(w.realDirs)-1]
 				}
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if reported == 0 && w.cfg.emptyDirHook != nil {
+		dir, err := w.reportPath(path)
+		if err != nil {
+			return err
+		}
+		w.cfg.emptyDirHook(dir)
+	}
+	if w.cfg.dirExitHook != nil {
+		dir, err := w.reportPath(path)
+		if err != nil {
+			return err
+		}
+		w.cfg.dirExitHook(dir)
+	}
+	return nil
+}
+
+// descends reports whether the walk descends into the directory at path,
+// already reported, as the filter given to WithDescendFilter decides.
+func (w *walker) descends(path string, d fs.DirEntry) (bool, error) {
+	if w.cfg.descendFilter == nil {
+		return true, nil
+	}
+	info, err := d.Info()
+	if err != nil {
+		return false, w.handleError(path, err)
+	}
+	reported, err := w.reportPath(path)
+	if err != nil {
+		return false, err
+	}
+	return w.cfg.descendFilter(reported, info), nil
+}
+
+// localRules returns the ignore patterns and attributes in effect in the
+// directory at path, whose entries are files: those it inherits, followed by
+// those of its own ignore files and (with WithExportIgnore) .gitattributes.
+// The inherited rules are shared with the parent and are only copied (by the
+// capped appends) when the directory adds rules of its own.
+func (w *walker) localRules(path string, domain []string, files []fs.DirEntry, patterns []SourcedPattern, attrs []gitattributes.MatchAttribute) ([]SourcedPattern, []gitattributes.MatchAttribute, error) {
+	filePatterns, ok := w.snapshot[path]
+	if !ok {
+		var err error
+		filePatterns, err = w.cfg.ignorePatternsIn(path, domain, files, w.cache)
+		if err != nil {
+			return nil, nil, err
+		}
+		if w.snapshot != nil {
+			w.snapshot[path] = filePatterns
+		}
+	}
+	patterns = append(patterns[:len(patterns):len(patterns)], filePatterns...)
+
+	if w.cfg.exportIgnore {
+		fileAttrs, err := attributesIn(path, domain, files, path == w.root)
+		if err != nil {
+			return nil, nil, err
+		}
+		attrs = append(attrs[:len(attrs):len(attrs)], fileAttrs...)
+	}
+	return patterns, attrs, nil
+}
+
+// decide reports whether the entry at path, given as components relative to
+// the root, is ignored, and returns what decided it: a simple exclude glob, an
+// excluding regular expression, "export-ignore", the text of an ignore
+// pattern, or "" if nothing matched. attrMatcher is nil when no attributes
+// apply.
+func (w *walker) decide(path []string, isDir bool, patterns []SourcedPattern, attrMatcher gitattributes.Matcher) (string, bool) {
+	if glob := w.cfg.simpleExclude(path[len(path)-1]); glob != "" {
+		return glob, true
+	}
+	if len(w.cfg.excludeRegexps) > 0 {
+		rel := strings.Join(path[len(w.cfg.domainPrefix):], "/")
+		for _, re := range w.cfg.excludeRegexps {
+			if re.MatchString(rel) {
+				return re.String(), true
 			}
 		}
+	}
+	if attrMatcher != nil && exportIgnored(attrMatcher, path) {
+		return "export-ignore", true
+	}
+	if sp, excluded := w.match(patterns, path, isDir); sp != nil {
+		return sp.Text, excluded
+	}
+	return "", false
+}
+
+// match reports whether patterns exclude path, as a gitignore.Matcher does:
+// the last pattern to match the path decides. It returns the deciding
+// pattern, or nil if none matched. Each pattern evaluated is counted in the
+// walk's Stats.
+func (w *walker) match(patterns []SourcedPattern, path []string, isDir bool) (*SourcedPattern, bool) {
+	for i := len(patterns) - 1; i >= 0; i-- {
+		w.stats.Evaluations++
+		if m := matchPattern(patterns[i], path, isDir, w.cfg.ignoreCase); m != gitignore.NoMatch {
+			return &patterns[i], m == gitignore.Exclude
+		}
+	}
+	return nil, false
+}
+
+// handleError decides whether err, encountered at path, aborts the walk. The
+// caller's error handler has the final say; without one, entries which have
+// vanished since their directory was listed are skipped, as are directories
+// which have since been replaced by files. Errors at the root always abort.
+func (w *walker) handleError(path string, err error) error {
+	handled := w.decideError(path, err)
+	if w.cfg.logger != nil {
+		w.cfg.logger.Debug("walkrepo: error", "path", path, "error", err, "skipped", handled == nil)
+	}
+	return handled
+}
 
+func (w *walker) decideError(path string, err error) error {
+	if path == w.root {
+		return err
+	}
+	if w.cfg.errorHandler != nil {
+		return w.cfg.errorHandler(path, err)
+	}
+	if os.IsNotExist(err) || errors.Is(err, syscall.ENOTDIR) {
 		return nil
 	}
+	return err
+}
 
-	return walk(root, domain, ps)
+// listDir lists the entries of the directory at path with readDir, retrying
+// transient failures as WithRetries allows.
+func (c *config) listDir(path string) ([]fs.DirEntry, error) {
+	files, err := readDir(path)
+	backoff := c.retryBackoff
+	for retry := 0; retry < c.retries && err != nil && isTransient(err); retry++ {
+		time.Sleep(backoff)
+		backoff *= 2
+		files, err = readDir(path)
+	}
+	return files, err
 }
 
-// parseFilePatterns parses the .gitignore file and returns a list of gitignore.Patterns.
-func parseFilePatterns(path string, domain []string) ([]gitignore.Pattern, error) {
-	if !strings.HasSuffix(path, ".gitignore") {
-		return nil, fmt.Errorf("file %s is not a .gitignore file", path)
+// isTransient reports whether err is one which may not recur if the failed
+// operation is tried again.
+func isTransient(err error) bool {
+	for _, errno := range []syscall.Errno{syscall.EAGAIN, syscall.EINTR, syscall.EBUSY, syscall.ETIMEDOUT} {
+		if errors.Is(err, errno) {
+			return true
+		}
 	}
+	return false
+}
 
-	fileBytes, err := os.ReadFile(path)
+// readDir lists the entries of the directory at path, in directory order. It
+// is a variable so that tests can inject failures.
+var readDir = func(path string) ([]fs.DirEntry, error) {
+	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
+	defer f.Close()
 
-	filePatterns := []gitignore.Pattern{}
+	return f.ReadDir(-1)
+}
 
-	// Split the contents of the .gitignore file into rawPatterns
-	rawPatterns := strings.Split(string(fileBytes), "\n")
-	for _, rawPattern := range rawPatterns {
-		// Ignore empty lines and comments
-		if rawPattern == "" || strings.HasPrefix(rawPattern, "#") {
-			continue
+// isIgnoreFile reports whether name is that of an ignore file. With ignore
+// rules disabled, no file is.
+func (c *config) isIgnoreFile(name string) bool {
+	if c.noIgnoreRules {
+		return false
+	}
+	for _, ignoreFile := range c.ignoreFiles {
+		if match, _ := filepath.Match(ignoreFile, name); match {
+			return true
+		}
+	}
+	return false
+}
+
+// simpleExclude returns the first of the globs given to WithSimpleExcludes
+// which matches name, or "" if none does.
+func (c *config) simpleExclude(name string) string {
+	for _, glob := range c.simpleExcludes {
+		if matchName(glob, name, false) {
+			return glob
 		}
-		pattern := gitignore.ParsePattern(rawPattern, domain)
+	}
+	return ""
+}
+
+// ErrIgnoreFileTooLarge is returned (wrapped in an *fs.PathError) for an
+// ignore file larger than the limit set by WithMaxIgnoreFileSize.
+var ErrIgnoreFileTooLarge = errors.New("walkrepo: ignore file too large")
 
-		filePatterns = append(filePatterns, pattern)
+// ignorePatternsIn returns the patterns of the ignore files among files, the
+// entries of the directory dir, consulting cache for files already parsed.
+// Where several ignore files are present, their patterns are layered in the
+// order the ignore files were configured, and files matched by the same glob
+// are layered in name order.
+func (c *config) ignorePatternsIn(dir string, domain []string, files []fs.DirEntry, cache *patternCache) ([]SourcedPattern, error) {
+	if c.noIgnoreRules {
+		return nil, nil
+	}
+	var sps []SourcedPattern
+	seen := make(map[string]bool)
+	for _, ignoreFile := range c.ignoreFiles {
+		var matched []fs.DirEntry
+		for _, file := range files {
+			if match, _ := filepath.Match(ignoreFile, file.Name()); match && !seen[file.Name()] {
+				seen[file.Name()] = true
+				matched = append(matched, file)
+			}
+		}
+		sort.Slice(matched, func(i, j int) bool { return matched[i].Name() < matched[j].Name() })
+
+		for _, file := range matched {
+			filePath := filepath.Join(dir, file.Name())
+			// Symlinked ignore files are read through, with the target's
+			// details validating any cached patterns.
+			var info os.FileInfo
+			var err error
+			if file.Type()&fs.ModeSymlink != 0 {
+				info, err = os.Stat(filePath)
+			} else {
+				info, err = file.Info()
+			}
+			if os.IsNotExist(err) {
+				// Removed since the directory was listed; it has no rules.
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+			if !info.Mode().IsRegular() {
+				// A directory (or anything else) by the name of an ignore
+				// file holds no rules.
+				continue
+			}
+			if c.maxIgnoreFileSize > 0 && info.Size() > c.maxIgnoreFileSize {
+				return nil, &fs.PathError{Op: "read", Path: filePath, Err: ErrIgnoreFileTooLarge}
+			}
+			filePatterns, err := cache.parse(filePath, domain, info)
+			if os.IsNotExist(err) {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+			sps = append(sps, filePatterns...)
+		}
 	}
-	return filePatterns, nil
+	return sps, nil
 }
@@ -1,9 +1,18 @@
 package walkrepo
 
 import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/rand"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
 )
 
 func TestWalkRepo(t *testing.T) {
@@ -165,6 +174,302 @@ func TestWalkRepo(t *testing.T) {
 				"empty/.gitkeep",
 			},
 		},
+		{
+			name: "pattern without a slash matches at any depth",
+			files: map[string]string{
+				"top.log":             "content",
+				"a/b/c/d/e/deep.log":  "content",
+				"a/b/c/d/e/deep.txt":  "content",
+				"a/b/c/d/e/f.log/g":   "content",
+				"x/y/z/notes.log.txt": "content",
+			},
+			gitignores: map[string]string{
+				".gitignore": "*.log\n",
+			},
+			expectedWalk: []string{
+				"a/b/c/d/e/deep.txt",
+				"x/y/z/notes.log.txt",
+			},
+			notExpected: []string{
+				"top.log",
+				"a/b/c/d/e/deep.log",
+				"a/b/c/d/e/f.log",
+				"a/b/c/d/e/f.log/g",
+			},
+		},
+		{
+			name: "pattern with a slash matches only where anchored",
+			files: map[string]string{
+				"logs/debug.txt":       "content",
+				"a/logs/debug.txt":     "content",
+				"a/b/logs/debug.txt":   "content",
+				"a/b/logs/release.txt": "content",
+			},
+			gitignores: map[string]string{
+				".gitignore":   "logs/debug.txt\n",
+				"a/.gitignore": "b/logs/*.txt\n",
+			},
+			expectedWalk: []string{
+				"logs",
+				"a/logs/debug.txt",
+				"a/b/logs",
+			},
+			notExpected: []string{
+				"logs/debug.txt",
+				"a/b/logs/debug.txt",
+				"a/b/logs/release.txt",
+			},
+		},
+		{
+			name: "middle slash anchors to the .gitignore",
+			files: map[string]string{
+				"doc/build/out.txt":     "content",
+				"src/doc/build/out.txt": "content",
+			},
+			gitignores: map[string]string{
+				".gitignore": "doc/build",
+			},
+			expectedWalk: []string{
+				"doc",
+				"src/doc/build",
+				"src/doc/build/out.txt",
+			},
+			notExpected: []string{
+				"doc/build",
+				"doc/build/out.txt",
+			},
+		},
+		{
+			name: "middle slash in nested gitignore",
+			files: map[string]string{
+				"doc/build/out.txt":       "content",
+				"sub/doc/build/out.txt":   "content",
+				"sub/x/doc/build/out.txt": "content",
+				"sub/y/doc/build/out.txt": "content",
+			},
+			gitignores: map[string]string{
+				"sub/.gitignore": "doc/build",
+			},
+			expectedWalk: []string{
+				"doc/build/out.txt",
+				"sub/doc",
+				"sub/x/doc/build/out.txt",
+				"sub/y/doc/build/out.txt",
+			},
+			notExpected: []string{
+				"sub/doc/build",
+				"sub/doc/build/out.txt",
+			},
+		},
+		{
+			name: "ignore everything, keep src tree",
+			files: map[string]string{
+				"build/out.txt":  "content",
+				"readme.md":      "content",
+				"src/main.go":    "content",
+				"src/lib/lib.go": "content",
+				"src/lib/x/y.go": "content",
+				"other/src/a.go": "content",
+			},
+			gitignores: map[string]string{
+				".gitignore": "*\n!src/\n!src/**\n",
+			},
+			expectedWalk: []string{
+				"src",
+				"src/main.go",
+				"src/lib",
+				"src/lib/lib.go",
+				"src/lib/x",
+				"src/lib/x/y.go",
+			},
+			notExpected: []string{
+				"build",
+				"build/out.txt",
+				"readme.md",
+				"other",
+				"other/src/a.go",
+			},
+		},
+		{
+			name: "re-included directory keeps its contents ignored",
+			files: map[string]string{
+				"src/main.go":    "content",
+				"src/lib/lib.go": "content",
+			},
+			gitignores: map[string]string{
+				".gitignore": "*\n!src/\n",
+			},
+			expectedWalk: []string{
+				"src",
+			},
+			notExpected: []string{
+				"src/main.go",
+				"src/lib",
+				"src/lib/lib.go",
+			},
+		},
+		{
+			name: "contents cannot be re-included beneath an excluded directory",
+			files: map[string]string{
+				"src/main.go": "content",
+			},
+			gitignores: map[string]string{
+				".gitignore": "*\n!src/**\n",
+			},
+			notExpected: []string{
+				"src",
+				"src/main.go",
+			},
+		},
+		{
+			name: "file cannot be re-included in a directory excluded by dir/",
+			files: map[string]string{
+				"build/keep.txt": "content",
+				"build/out.o":    "content",
+			},
+			gitignores: map[string]string{
+				".gitignore": "build/\n!build/keep.txt\n",
+			},
+			notExpected: []string{
+				"build",
+				"build/keep.txt",
+				"build/out.o",
+			},
+		},
+		{
+			name: "file can be re-included in a directory whose contents are excluded by dir/*",
+			files: map[string]string{
+				"build/keep.txt": "content",
+				"build/out.o":    "content",
+			},
+			gitignores: map[string]string{
+				".gitignore": "build/*\n!build/keep.txt\n",
+			},
+			expectedWalk: []string{
+				"build",
+				"build/keep.txt",
+			},
+			notExpected: []string{
+				"build/out.o",
+			},
+		},
+		{
+			name: "directory negation re-includes a directory and its contents",
+			files: map[string]string{
+				"build/keep/a":     "content",
+				"build/keep/sub/b": "content",
+				"build/other/c":    "content",
+				"build/top":        "content",
+				"build/keepfile":   "content",
+			},
+			gitignores: map[string]string{
+				".gitignore": "build/*\n!build/keep/\n",
+			},
+			expectedWalk: []string{
+				"build",
+				"build/keep",
+				"build/keep/a",
+				"build/keep/sub",
+				"build/keep/sub/b",
+			},
+			notExpected: []string{
+				"build/other",
+				"build/other/c",
+				"build/top",
+				"build/keepfile",
+			},
+		},
+		{
+			name: "directory negation cannot reach beneath an excluded directory",
+			files: map[string]string{
+				"build/keep/a": "content",
+				"build/top":    "content",
+			},
+			gitignores: map[string]string{
+				".gitignore": "build/\n!build/keep/\n",
+			},
+			notExpected: []string{
+				"build",
+				"build/keep",
+				"build/keep/a",
+				"build/top",
+			},
+		},
+		{
+			name: "ignore everything but directories and go files",
+			files: map[string]string{
+				"main.go":        "content",
+				"readme.md":      "content",
+				"pkg/a/a.go":     "content",
+				"pkg/a/notes.md": "content",
+			},
+			gitignores: map[string]string{
+				".gitignore": "*\n!*/\n!*.go\n",
+			},
+			expectedWalk: []string{
+				"main.go",
+				"pkg",
+				"pkg/a",
+				"pkg/a/a.go",
+			},
+			notExpected: []string{
+				"readme.md",
+				"pkg/a/notes.md",
+			},
+		},
+		{
+			name: "double star matches zero or more directories",
+			files: map[string]string{
+				"a/keep":    "content",
+				"a/b/f":     "content",
+				"a/x/b/f":   "content",
+				"a/x/y/b/f": "content",
+				"a/x/y/c/f": "content",
+				"c/a/b/f":   "content",
+				"a/bb/f":    "content",
+			},
+			gitignores: map[string]string{
+				".gitignore": "a/**/b",
+			},
+			expectedWalk: []string{
+				"a/keep",
+				"a/x/y/c/f",
+				"c/a/b",
+				"c/a/b/f",
+				"a/bb/f",
+			},
+			notExpected: []string{
+				"a/b",
+				"a/b/f",
+				"a/x/b",
+				"a/x/y/b",
+				"a/x/y/b/f",
+			},
+		},
+		{
+			name: "double star in nested gitignore",
+			files: map[string]string{
+				"a/b/f":         "content",
+				"sub/a/keep":    "content",
+				"sub/a/b/f":     "content",
+				"sub/a/x/y/b/f": "content",
+				"sub/c/a/b/f":   "content",
+			},
+			gitignores: map[string]string{
+				"sub/.gitignore": "a/**/b",
+			},
+			expectedWalk: []string{
+				"a/b/f",
+				"sub/a/keep",
+				"sub/a/x/y",
+				"sub/c/a/b/f",
+			},
+			notExpected: []string{
+				"sub/a/b",
+				"sub/a/b/f",
+				"sub/a/x/y/b",
+			},
+		},
 		{
 			name: "wildcard patterns",
 			files: map[string]string{
@@ -183,74 +488,1510 @@ func TestWalkRepo(t *testing.T) {
 				"bar.test.ts",
 			},
 		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			// Create temporary directory for test
-			tmpDir, err := os.MkdirTemp("", "walkrepo-test-*")
-			if err != nil {
-				t.Fatal(err)
-			}
-			defer os.RemoveAll(tmpDir)
-
-			// Create test files and directories
-			for path, content := range tt.files {
-				fullPath := filepath.Join(tmpDir, path)
-				err := os.MkdirAll(filepath.Dir(fullPath), 0755)
-				if err != nil {
-					t.Fatal(err)
-				}
-				err = os.WriteFile(fullPath, []byte(content), 0644)
-				if err != nil {
-					t.Fatal(err)
-				}
-			}
-
-			// Create .gitignore files
-			for path, content := range tt.gitignores {
-				fullPath := filepath.Join(tmpDir, path)
-				err := os.MkdirAll(filepath.Dir(fullPath), 0755)
-				if err != nil {
-					t.Fatal(err)
-				}
-				err = os.WriteFile(fullPath, []byte(content), 0644)
-				if err != nil {
-					t.Fatal(err)
-				}
-			}
-
-			// Track walked paths
-			walked := make(map[string]bool)
-			err = WalkRepo(tmpDir, func(path string, info os.FileInfo, err error) error {
-				if err != nil {
-					return err
-				}
-				relPath, err := filepath.Rel(tmpDir, path)
-				if err != nil {
-					t.Fatal(err)
-				}
-				walked[relPath] = true
-				return nil
-			})
-
-			if err != nil {
-				t.Errorf("WalkRepo() error = %v", err)
-			}
-
-			// Verify expected paths were walked
-			for _, expected := range tt.expectedWalk {
-				if !walked[expected] {
-					t.Errorf("expected path %q was not walked", expected)
-				}
-			}
-
-			// Verify not expected paths were not walked
-			for _, notExpected := range tt.notExpected {
-				if walked[notExpected] {
-					t.Errorf("path %q was walked but should have been ignored", notExpected)
-				}
-			}
-		})
+		{
+			name: "duplicate and contradictory patterns in one file",
+			files: map[string]string{
+				"a.txt": "content",
+				"b.txt": "content",
+				"c.txt": "content",
+				"d.txt": "content",
+			},
+			gitignores: map[string]string{
+				".gitignore": "a.txt\na.txt\n!b.txt\nb.txt\nc.txt\n!c.txt\n!d.txt\n!d.txt\n",
+			},
+			expectedWalk: []string{
+				"c.txt",
+				"d.txt",
+			},
+			notExpected: []string{
+				"a.txt",
+				"b.txt",
+			},
+		},
+		{
+			name: "duplicate and contradictory patterns across nested files",
+			files: map[string]string{
+				"a.txt":     "content",
+				"b.log":     "content",
+				"sub/a.txt": "content",
+				"sub/b.log": "content",
+				"sub/c.txt": "content",
+				"sub/d.txt": "content",
+			},
+			gitignores: map[string]string{
+				".gitignore":     "a.txt\n*.log\nc.txt\n!d.txt\n",
+				"sub/.gitignore": "!a.txt\n!*.log\n*.log\nc.txt\nd.txt\n!d.txt\n",
+			},
+			expectedWalk: []string{
+				"sub/a.txt",
+				"sub/d.txt",
+			},
+			notExpected: []string{
+				"a.txt",
+				"b.log",
+				"sub/b.log",
+				"sub/c.txt",
+			},
+		},
+		{
+			name: "directory ignored, re-included, then file re-ignored in one file",
+			files: map[string]string{
+				"a/secret":   "content",
+				"a/public":   "content",
+				"a/b/secret": "content",
+			},
+			gitignores: map[string]string{
+				".gitignore": "a/\n!a/\na/secret\n",
+			},
+			expectedWalk: []string{
+				"a",
+				"a/public",
+				"a/b",
+				"a/b/secret",
+			},
+			notExpected: []string{
+				"a/secret",
+			},
+		},
+		{
+			name: "directory ignored, re-included, then file re-ignored across files",
+			files: map[string]string{
+				"a/public":   "content",
+				"x/a/secret": "content",
+				"x/a/public": "content",
+				"x/a/b/c":    "content",
+				"y/a/f":      "content",
+			},
+			gitignores: map[string]string{
+				".gitignore":   "a/\n",
+				"x/.gitignore": "!a/\na/secret\n",
+			},
+			expectedWalk: []string{
+				"x/a",
+				"x/a/public",
+				"x/a/b",
+				"x/a/b/c",
+				"y",
+			},
+			notExpected: []string{
+				"a",
+				"a/public",
+				"x/a/secret",
+				"y/a",
+				"y/a/f",
+			},
+		},
+		{
+			name: "directory ignored, re-included, then files re-ignored and re-included at three levels",
+			files: map[string]string{
+				"x/a/secret":   "content",
+				"x/a/public":   "content",
+				"x/a/b/secret": "content",
+				"x/a/b/c":      "content",
+			},
+			gitignores: map[string]string{
+				".gitignore":     "a/\n",
+				"x/.gitignore":   "!a/\n",
+				"x/a/.gitignore": "secret\n!b/secret\n",
+			},
+			expectedWalk: []string{
+				"x/a",
+				"x/a/public",
+				"x/a/b",
+				"x/a/b/secret",
+				"x/a/b/c",
+			},
+			notExpected: []string{
+				"x/a/secret",
+			},
+		},
+		{
+			// A directory's own ignore file is never read if the directory
+			// is excluded, so can't re-include it; a negation in a
+			// directory between them can.
+			name: "directory-only exclusion negated by nested ignore files",
+			files: map[string]string{
+				"logs/x":        "content",
+				"logs/logs/y":   "content",
+				"sub/logs/z":    "content",
+				"a/b/logs/w":    "content",
+				"other/logs/v":  "content",
+				"other/logs.md": "content",
+			},
+			gitignores: map[string]string{
+				".gitignore":      "logs/\n",
+				"logs/.gitignore": "!logs/\n",
+				"sub/.gitignore":  "!logs/\n",
+				"a/.gitignore":    "!logs/\n",
+			},
+			expectedWalk: []string{
+				"sub/logs",
+				"sub/logs/z",
+				"a/b/logs",
+				"a/b/logs/w",
+				"other/logs.md",
+			},
+			notExpected: []string{
+				"logs",
+				"logs/x",
+				"logs/logs/y",
+				"other/logs",
+				"other/logs/v",
+			},
+		},
+		{
+			name: "nested negations of a parent's directory-only exclusion",
+			files: map[string]string{
+				"sub/logs/x":      "content",
+				"sub/logs/y":      "content",
+				"sub/deep/logs/z": "content",
+				"alt/logs/x":      "content",
+			},
+			gitignores: map[string]string{
+				".gitignore":     "logs/\n",
+				"sub/.gitignore": "!/logs/\n",
+				"alt/.gitignore": "!logs/x\n",
+			},
+			expectedWalk: []string{
+				"sub/logs",
+				"sub/logs/x",
+				"sub/logs/y",
+				"sub/deep",
+			},
+			notExpected: []string{
+				"sub/deep/logs",
+				"sub/deep/logs/z",
+				"alt/logs",
+				"alt/logs/x",
+			},
+		},
+		{
+			name: "leading dot-slash patterns match nothing",
+			files: map[string]string{
+				"build/a":         "content",
+				"sub/build/b":     "content",
+				"x/build/c":       "content",
+				"x/sub/build/out": "content",
+			},
+			gitignores: map[string]string{
+				".gitignore":     "./build\n./x/build/\n",
+				"sub/.gitignore": "./build\n",
+			},
+			expectedWalk: []string{
+				"build",
+				"build/a",
+				"sub/build",
+				"sub/build/b",
+				"x/build/c",
+				"x/sub/build/out",
+			},
+		},
+		{
+			name: "bare double asterisk with a negated directory",
+			files: map[string]string{
+				"a.txt":             "content",
+				"sub/keep.txt":      "content",
+				"sub/drop.txt":      "content",
+				"sub/deep/keep.txt": "content",
+				"other/keep.txt":    "content",
+			},
+			gitignores: map[string]string{
+				".gitignore": "**\n!sub/\n!keep.txt\n",
+			},
+			expectedWalk: []string{
+				"sub",
+				"sub/keep.txt",
+			},
+			notExpected: []string{
+				"a.txt",
+				"sub/drop.txt",
+				"sub/deep",
+				"sub/deep/keep.txt",
+				"other",
+				"other/keep.txt",
+			},
+		},
+		{
+			name: "bare double asterisk with all directories negated",
+			files: map[string]string{
+				"a.txt":             "content",
+				"sub/keep.txt":      "content",
+				"sub/drop.txt":      "content",
+				"sub/deep/keep.txt": "content",
+				"other/keep.txt":    "content",
+			},
+			gitignores: map[string]string{
+				".gitignore": "**\n!*/\n!keep.txt\n",
+			},
+			expectedWalk: []string{
+				"sub",
+				"sub/keep.txt",
+				"sub/deep",
+				"sub/deep/keep.txt",
+				"other",
+				"other/keep.txt",
+			},
+			notExpected: []string{
+				"a.txt",
+				"sub/drop.txt",
+			},
+		},
+		{
+			name: "bare double asterisk hides nested negations",
+			files: map[string]string{
+				"a.txt":        "content",
+				"sub/keep.txt": "content",
+			},
+			gitignores: map[string]string{
+				".gitignore":     "**\n",
+				"sub/.gitignore": "!*\n",
+			},
+			notExpected: []string{
+				"a.txt",
+				"sub",
+				"sub/keep.txt",
+			},
+		},
+		{
+			// Expectations taken from `git status --ignored` over the
+			// same trees. A negation, however recursive, can't reach into a
+			// directory which is itself excluded.
+			name: "recursive negation in a nested ignore file",
+			files: map[string]string{
+				"keep.txt":           "content",
+				"a.txt":              "content",
+				"sub/keep.txt":       "content",
+				"sub/a.txt":          "content",
+				"sub/a/keep.txt":     "content",
+				"sub/a/b/keep.txt":   "content",
+				"sub/a/b/c.txt":      "content",
+				"sub/tmp/keep.txt":   "content",
+				"sub/tmp/x/keep.txt": "content",
+				"sub/tmp/x/y.txt":    "content",
+				"other/keep.txt":     "content",
+			},
+			gitignores: map[string]string{
+				"sub/.gitignore": "*.txt\n!**/keep.txt\n",
+			},
+			expectedWalk: []string{
+				"a.txt",
+				"keep.txt",
+				"other",
+				"other/keep.txt",
+				"sub",
+				"sub/a",
+				"sub/a/b",
+				"sub/a/b/keep.txt",
+				"sub/a/keep.txt",
+				"sub/keep.txt",
+				"sub/tmp",
+				"sub/tmp/keep.txt",
+				"sub/tmp/x",
+				"sub/tmp/x/keep.txt",
+			},
+			notExpected: []string{
+				"sub/a/b/c.txt",
+				"sub/a.txt",
+				"sub/tmp/x/y.txt",
+			},
+		},
+		{
+			name: "recursive negation of a parent's exclusion",
+			files: map[string]string{
+				"keep.txt":           "content",
+				"a.txt":              "content",
+				"sub/keep.txt":       "content",
+				"sub/a.txt":          "content",
+				"sub/a/keep.txt":     "content",
+				"sub/a/b/keep.txt":   "content",
+				"sub/a/b/c.txt":      "content",
+				"sub/tmp/keep.txt":   "content",
+				"sub/tmp/x/keep.txt": "content",
+				"sub/tmp/x/y.txt":    "content",
+				"other/keep.txt":     "content",
+			},
+			gitignores: map[string]string{
+				".gitignore":     "*.txt\n",
+				"sub/.gitignore": "!**/keep.txt\n",
+			},
+			expectedWalk: []string{
+				"other",
+				"sub",
+				"sub/a",
+				"sub/a/b",
+				"sub/a/b/keep.txt",
+				"sub/a/keep.txt",
+				"sub/keep.txt",
+				"sub/tmp",
+				"sub/tmp/keep.txt",
+				"sub/tmp/x",
+				"sub/tmp/x/keep.txt",
+			},
+			notExpected: []string{
+				"a.txt",
+				"keep.txt",
+				"other/keep.txt",
+				"sub/a/b/c.txt",
+				"sub/a.txt",
+				"sub/tmp/x/y.txt",
+			},
+		},
+		{
+			name: "recursive negation within an excluded directory",
+			files: map[string]string{
+				"keep.txt":           "content",
+				"a.txt":              "content",
+				"sub/keep.txt":       "content",
+				"sub/a.txt":          "content",
+				"sub/a/keep.txt":     "content",
+				"sub/a/b/keep.txt":   "content",
+				"sub/a/b/c.txt":      "content",
+				"sub/tmp/keep.txt":   "content",
+				"sub/tmp/x/keep.txt": "content",
+				"sub/tmp/x/y.txt":    "content",
+				"other/keep.txt":     "content",
+			},
+			gitignores: map[string]string{
+				"sub/.gitignore": "*.txt\ntmp/\n!**/keep.txt\n",
+			},
+			expectedWalk: []string{
+				"a.txt",
+				"keep.txt",
+				"other",
+				"other/keep.txt",
+				"sub",
+				"sub/a",
+				"sub/a/b",
+				"sub/a/b/keep.txt",
+				"sub/a/keep.txt",
+				"sub/keep.txt",
+			},
+			notExpected: []string{
+				"sub/a/b/c.txt",
+				"sub/a.txt",
+				"sub/tmp",
+				"sub/tmp/keep.txt",
+				"sub/tmp/x",
+				"sub/tmp/x/keep.txt",
+				"sub/tmp/x/y.txt",
+			},
+		},
+		{
+			name: "recursive negation within an excluded directory's contents",
+			files: map[string]string{
+				"keep.txt":           "content",
+				"a.txt":              "content",
+				"sub/keep.txt":       "content",
+				"sub/a.txt":          "content",
+				"sub/a/keep.txt":     "content",
+				"sub/a/b/keep.txt":   "content",
+				"sub/a/b/c.txt":      "content",
+				"sub/tmp/keep.txt":   "content",
+				"sub/tmp/x/keep.txt": "content",
+				"sub/tmp/x/y.txt":    "content",
+				"other/keep.txt":     "content",
+			},
+			gitignores: map[string]string{
+				"sub/.gitignore": "*.txt\ntmp/*\n!**/keep.txt\n",
+			},
+			expectedWalk: []string{
+				"a.txt",
+				"keep.txt",
+				"other",
+				"other/keep.txt",
+				"sub",
+				"sub/a",
+				"sub/a/b",
+				"sub/a/b/keep.txt",
+				"sub/a/keep.txt",
+				"sub/keep.txt",
+				"sub/tmp",
+				"sub/tmp/keep.txt",
+			},
+			notExpected: []string{
+				"sub/a/b/c.txt",
+				"sub/a.txt",
+				"sub/tmp/x",
+				"sub/tmp/x/keep.txt",
+				"sub/tmp/x/y.txt",
+			},
+		},
+		{
+			name: "negation within a directory excluded by name",
+			files: map[string]string{
+				"foo/bar":     "content",
+				"foo/baz":     "content",
+				"sub/foo/bar": "content",
+			},
+			gitignores: map[string]string{
+				".gitignore": "foo\n!foo/bar\n",
+			},
+			expectedWalk: []string{
+				"sub",
+			},
+			notExpected: []string{
+				"foo",
+				"foo/bar",
+				"foo/baz",
+				"sub/foo",
+				"sub/foo/bar",
+			},
+		},
+		{
+			name: "negation within a directory excluded as a directory",
+			files: map[string]string{
+				"foo/bar":     "content",
+				"foo/baz":     "content",
+				"sub/foo/bar": "content",
+			},
+			gitignores: map[string]string{
+				".gitignore": "foo/\n!foo/bar\n",
+			},
+			expectedWalk: []string{
+				"sub",
+			},
+			notExpected: []string{
+				"foo",
+				"foo/bar",
+				"foo/baz",
+				"sub/foo",
+				"sub/foo/bar",
+			},
+		},
+		{
+			name: "negation within a directory whose contents are excluded",
+			files: map[string]string{
+				"foo/bar":     "content",
+				"foo/baz":     "content",
+				"sub/foo/bar": "content",
+			},
+			gitignores: map[string]string{
+				".gitignore": "foo/*\n!foo/bar\n",
+			},
+			expectedWalk: []string{
+				"foo",
+				"foo/bar",
+				"sub",
+				"sub/foo",
+				"sub/foo/bar",
+			},
+			notExpected: []string{
+				"foo/baz",
+			},
+		},
+		{
+			name: "recursive negation with every subdirectory re-included",
+			files: map[string]string{
+				"keep.txt":           "content",
+				"a.txt":              "content",
+				"sub/keep.txt":       "content",
+				"sub/a.txt":          "content",
+				"sub/a/keep.txt":     "content",
+				"sub/a/b/keep.txt":   "content",
+				"sub/a/b/c.txt":      "content",
+				"sub/tmp/keep.txt":   "content",
+				"sub/tmp/x/keep.txt": "content",
+				"sub/tmp/x/y.txt":    "content",
+				"other/keep.txt":     "content",
+			},
+			gitignores: map[string]string{
+				"sub/.gitignore": "tmp/**\n!tmp/**/\n!**/keep.txt\n",
+			},
+			expectedWalk: []string{
+				"a.txt",
+				"keep.txt",
+				"other",
+				"other/keep.txt",
+				"sub",
+				"sub/a",
+				"sub/a/b",
+				"sub/a/b/c.txt",
+				"sub/a/b/keep.txt",
+				"sub/a/keep.txt",
+				"sub/a.txt",
+				"sub/keep.txt",
+				"sub/tmp",
+				"sub/tmp/keep.txt",
+				"sub/tmp/x",
+				"sub/tmp/x/keep.txt",
+			},
+			notExpected: []string{
+				"sub/tmp/x/y.txt",
+			},
+		},
+		{
+			name: "recursive negation within a directory the parent excludes",
+			files: map[string]string{
+				"keep.txt":           "content",
+				"a.txt":              "content",
+				"sub/keep.txt":       "content",
+				"sub/a.txt":          "content",
+				"sub/a/keep.txt":     "content",
+				"sub/a/b/keep.txt":   "content",
+				"sub/a/b/c.txt":      "content",
+				"sub/tmp/keep.txt":   "content",
+				"sub/tmp/x/keep.txt": "content",
+				"sub/tmp/x/y.txt":    "content",
+				"other/keep.txt":     "content",
+			},
+			gitignores: map[string]string{
+				".gitignore":     "sub/tmp/\n",
+				"sub/.gitignore": "!**/keep.txt\n",
+			},
+			expectedWalk: []string{
+				"a.txt",
+				"keep.txt",
+				"other",
+				"other/keep.txt",
+				"sub",
+				"sub/a",
+				"sub/a/b",
+				"sub/a/b/c.txt",
+				"sub/a/b/keep.txt",
+				"sub/a/keep.txt",
+				"sub/a.txt",
+				"sub/keep.txt",
+			},
+			notExpected: []string{
+				"sub/tmp",
+				"sub/tmp/keep.txt",
+				"sub/tmp/x",
+				"sub/tmp/x/keep.txt",
+				"sub/tmp/x/y.txt",
+			},
+		},
+		{
+			// Patterns naming paths which don't exist match nothing, and are
+			// no error; as decided by git status over the same tree.
+			name: "patterns for nonexistent paths",
+			files: map[string]string{
+				"a.txt":               "content",
+				"nonexistent.txt":     "content",
+				"sub/b.txt":           "content",
+				"sub/nonexistent":     "content",
+				"sub/deep/missing.md": "content",
+			},
+			gitignores: map[string]string{
+				".gitignore":     "nonexistent/**\n/missing/\nno/such/dir/*.txt\n!ghost\n**/nothere/**\nsub/missing\n",
+				"sub/.gitignore": "ghost/\n/nope/**\n",
+			},
+			expectedWalk: []string{
+				"a.txt",
+				"nonexistent.txt",
+				"sub",
+				"sub/b.txt",
+				"sub/nonexistent",
+				"sub/deep",
+				"sub/deep/missing.md",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Create temporary directory for test
+			tmpDir, err := os.MkdirTemp("", "walkrepo-test-*")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(tmpDir)
+
+			// Create test files and directories
+			for path, content := range tt.files {
+				fullPath := filepath.Join(tmpDir, path)
+				err := os.MkdirAll(filepath.Dir(fullPath), 0755)
+				if err != nil {
+					t.Fatal(err)
+				}
+				err = os.WriteFile(fullPath, []byte(content), 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// Create .gitignore files
+			for path, content := range tt.gitignores {
+				fullPath := filepath.Join(tmpDir, path)
+				err := os.MkdirAll(filepath.Dir(fullPath), 0755)
+				if err != nil {
+					t.Fatal(err)
+				}
+				err = os.WriteFile(fullPath, []byte(content), 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// Track walked paths
+			walked := make(map[string]bool)
+			err = WalkRepo(tmpDir, func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				relPath, err := filepath.Rel(tmpDir, path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				walked[relPath] = true
+				return nil
+			})
+
+			if err != nil {
+				t.Errorf("WalkRepo() error = %v", err)
+			}
+
+			// Verify expected paths were walked
+			for _, expected := range tt.expectedWalk {
+				if !walked[expected] {
+					t.Errorf("expected path %q was not walked", expected)
+				}
+			}
+
+			// Verify not expected paths were not walked
+			for _, notExpected := range tt.notExpected {
+				if walked[notExpected] {
+					t.Errorf("path %q was walked but should have been ignored", notExpected)
+				}
+			}
+		})
+	}
+}
+
+// writeTree creates the given files (path to content) beneath dir.
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for path, content := range files {
+		fullPath := filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// walkedPaths walks root with the given options, returning the set of
+// walked paths relative to root, with forward slashes.
+func walkedPaths(t *testing.T, root string, opts ...Option) map[string]bool {
+	t.Helper()
+	walked := make(map[string]bool)
+	err := WalkRepo(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		walked[filepath.ToSlash(relPath)] = true
+		return nil
+	}, opts...)
+	if err != nil {
+		t.Fatalf("WalkRepo() error = %v", err)
+	}
+	return walked
+}
+
+// assertWalked checks that every path in expected was walked, and that no
+// path in notExpected was.
+func assertWalked(t *testing.T, walked map[string]bool, expected, notExpected []string) {
+	t.Helper()
+	for _, path := range expected {
+		if !walked[path] {
+			t.Errorf("expected path %q was not walked", path)
+		}
+	}
+	for _, path := range notExpected {
+		if walked[path] {
+			t.Errorf("path %q was walked but should have been ignored", path)
+		}
+	}
+}
+
+// chdir changes the working directory for the duration of the test.
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func TestWalkRepoShortRoots(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTree(t, tmpDir, map[string]string{
+		"repo/.gitignore":    "/top.txt\nsub/*.log",
+		"repo/top.txt":       "content",
+		"repo/keep.txt":      "content",
+		"repo/sub/top.txt":   "content",
+		"repo/sub/debug.log": "content",
+		"repo/sub/other.txt": "content",
+	})
+	chdir(t, tmpDir)
+
+	for _, root := range []string{"repo", "repo/", "./repo", "repo/../repo"} {
+		t.Run(root, func(t *testing.T) {
+			walked := make(map[string]bool)
+			err := WalkRepo(root, func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				for _, c := range strings.Split(filepath.ToSlash(path), "/") {
+					if c == ".." {
+						t.Errorf("path %q contains a .. component", path)
+					}
+				}
+				walked[filepath.ToSlash(path)] = true
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("WalkRepo() error = %v", err)
+			}
+
+			for _, expected := range []string{"repo/keep.txt", "repo/sub", "repo/sub/top.txt", "repo/sub/other.txt"} {
+				if !walked[expected] {
+					t.Errorf("expected path %q was not walked", expected)
+				}
+			}
+			for _, notExpected := range []string{"repo/top.txt", "repo/sub/debug.log"} {
+				if walked[notExpected] {
+					t.Errorf("path %q was walked but should have been ignored", notExpected)
+				}
+			}
+		})
+	}
+}
+
+// buildBenchTree creates a tree of the given depth and width beneath dir,
+// with the given number of regular files in every directory. A .gitignore is written into
+// every ignoreEvery'th directory (or none, if ignoreEvery is 0).
+func buildBenchTree(b *testing.B, dir string, depth, width, files, ignoreEvery int) {
+	b.Helper()
+	count := 0
+	var build func(path string, level int)
+	build = func(path string, level int) {
+		if err := os.MkdirAll(path, 0755); err != nil {
+			b.Fatal(err)
+		}
+		if ignoreEvery > 0 && count%ignoreEvery == 0 {
+			err := os.WriteFile(filepath.Join(path, ".gitignore"), []byte("*.log\n!keep.log\n"), 0644)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+		count++
+		for i := 0; i < files; i++ {
+			name := filepath.Join(path, fmt.Sprintf("file%d.txt", i))
+			if err := os.WriteFile(name, nil, 0644); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if level == depth {
+			return
+		}
+		for i := 0; i < width; i++ {
+			build(filepath.Join(path, fmt.Sprintf("dir%d", i)), level+1)
+		}
+	}
+	build(dir, 0)
+}
+
+func BenchmarkWalkRepoDeepWide(b *testing.B) {
+	root := b.TempDir()
+	buildBenchTree(b, root, 4, 6, 8, 50)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := WalkRepo(root, func(path string, info os.FileInfo, err error) error {
+			return err
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkWalkRepoSparseIgnores(b *testing.B) {
+	root := b.TempDir()
+	buildBenchTree(b, root, 5, 5, 2, 500)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := WalkRepo(root, func(path string, info os.FileInfo, err error) error {
+			return err
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkWalkRepoShapes walks trees of several shapes, from wide and
+// shallow to narrow and deep, with ignore files in every directory, a few or
+// none.
+func BenchmarkWalkRepoShapes(b *testing.B) {
+	shapes := []struct {
+		name                             string
+		depth, width, files, ignoreEvery int
+	}{
+		{"wide", 2, 30, 20, 0},
+		{"wide-ignores", 2, 30, 20, 1},
+		{"deep", 9, 2, 4, 0},
+		{"deep-ignores", 9, 2, 4, 1},
+		{"balanced-sparse-ignores", 4, 6, 8, 25},
+	}
+	for _, shape := range shapes {
+		root := b.TempDir()
+		buildBenchTree(b, root, shape.depth, shape.width, shape.files, shape.ignoreEvery)
+		b.Run(shape.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				err := WalkRepoDir(root, func(path string, d fs.DirEntry, err error) error {
+					return err
+				})
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestWalkRepoVanishingEntries(t *testing.T) {
+	setup := func(t *testing.T) string {
+		root := t.TempDir()
+		writeTree(t, root, map[string]string{
+			"a/file.txt": "content",
+			"b/file.txt": "content",
+		})
+		return root
+	}
+
+	// Whichever of a and b is visited first removes the other, which has
+	// already been listed by the time it is descended into.
+	// With replace set, the other directory is replaced by a file of the
+	// same name.
+	vanish := func(root string, walked map[string]bool, replace bool) filepath.WalkFunc {
+		removed := false
+		return func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, _ := filepath.Rel(root, path)
+			walked[filepath.ToSlash(rel)] = true
+			if info.IsDir() && !removed {
+				removed = true
+				other := filepath.Join(root, map[string]string{"a": "b", "b": "a"}[rel])
+				if err := os.RemoveAll(other); err != nil || !replace {
+					return err
+				}
+				return os.WriteFile(other, []byte("content"), 0644)
+			}
+			return nil
+		}
+	}
+
+	t.Run("skipped by default", func(t *testing.T) {
+		root := setup(t)
+		walked := make(map[string]bool)
+		if err := WalkRepo(root, vanish(root, walked, false)); err != nil {
+			t.Fatalf("WalkRepo() error = %v", err)
+		}
+		if !walked["a/file.txt"] && !walked["b/file.txt"] {
+			t.Errorf("the surviving directory was not walked: %v", walked)
+		}
+	})
+
+	t.Run("replaced by a file", func(t *testing.T) {
+		root := setup(t)
+		walked := make(map[string]bool)
+		if err := WalkRepo(root, vanish(root, walked, true)); err != nil {
+			t.Fatalf("WalkRepo() error = %v", err)
+		}
+		if !walked["a/file.txt"] && !walked["b/file.txt"] {
+			t.Errorf("the surviving directory was not walked: %v", walked)
+		}
+	})
+
+	t.Run("surfaced to the error handler", func(t *testing.T) {
+		root := setup(t)
+		walked := make(map[string]bool)
+		var handled []string
+		err := WalkRepo(root, vanish(root, walked, false), WithErrorHandler(func(path string, err error) error {
+			if !os.IsNotExist(err) {
+				return err
+			}
+			handled = append(handled, path)
+			return nil
+		}))
+		if err != nil {
+			t.Fatalf("WalkRepo() error = %v", err)
+		}
+		if len(handled) != 1 {
+			t.Errorf("error handler called for %v, want one vanished directory", handled)
+		}
+	})
+
+	t.Run("aborted by the error handler", func(t *testing.T) {
+		root := setup(t)
+		walked := make(map[string]bool)
+		err := WalkRepo(root, vanish(root, walked, false), WithErrorHandler(func(path string, err error) error {
+			return err
+		}))
+		if !os.IsNotExist(err) {
+			t.Errorf("WalkRepo() error = %v, want a not-exist error", err)
+		}
+	})
+}
+
+func TestWalkRepoMissingRoot(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "missing")
+	err := WalkRepo(root, func(path string, info os.FileInfo, err error) error {
+		return err
+	})
+	if !os.IsNotExist(err) {
+		t.Errorf("WalkRepo() error = %v, want a not-exist error", err)
+	}
+}
+
+func TestWalkRepoLongPaths(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{".gitignore": "*.log\n"})
+
+	// Build a tree deeper than most systems' path limits, one directory at a
+	// time relative to the last, so that creating it never needs the full
+	// path.
+	name := strings.Repeat("d", 200)
+	chdir(t, root)
+	for i := 0; i < 25; i++ {
+		if err := os.Mkdir(name, 0755); err != nil {
+			t.Skipf("cannot build deep tree: %v", err)
+		}
+		if err := os.Chdir(name); err != nil {
+			t.Skipf("cannot build deep tree: %v", err)
+		}
+		for _, file := range []string{"f.txt", "f.log"} {
+			if err := os.WriteFile(file, nil, 0644); err != nil {
+				t.Skipf("cannot build deep tree: %v", err)
+			}
+		}
+	}
+
+	noop := func(path string, info os.FileInfo, err error) error { return err }
+	err := WalkRepo(root, noop)
+	if err == nil {
+		t.Skip("no path length limit was reached")
+	}
+	var pathErr *fs.PathError
+	if !errors.As(err, &pathErr) || !strings.HasPrefix(pathErr.Path, root) {
+		t.Errorf("WalkRepo() error = %v, want a PathError naming the long path", err)
+	}
+
+	walked := make(map[string]bool)
+	var handled []string
+	err = WalkRepo(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		walked[filepath.ToSlash(rel)] = true
+		return nil
+	}, WithErrorHandler(func(path string, err error) error {
+		var pathErr *fs.PathError
+		if !errors.As(err, &pathErr) || pathErr.Path != path {
+			t.Errorf("error %v does not name the path %q", err, path)
+		}
+		handled = append(handled, path)
+		return nil
+	}))
+	if err != nil {
+		t.Fatalf("WalkRepo() with error handler error = %v", err)
+	}
+	if len(handled) == 0 {
+		t.Errorf("error handler was never called")
+	}
+	assertWalked(t, walked, []string{name, name + "/f.txt", name + "/" + name + "/f.txt"}, []string{name + "/f.log"})
+}
+
+func TestWalkRepoWithIgnoreFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	root := filepath.Join(tmpDir, "repo")
+	writeTree(t, root, map[string]string{
+		".gitignore":      "!keep.bak",
+		"main.go":         "content",
+		"old.bak":         "content",
+		"keep.bak":        "content",
+		".idea/workspace": "content",
+		"sub/x.bak":       "content",
+		"sub/y.go":        "content",
+	})
+	ignoreFile := filepath.Join(tmpDir, "org.gitignore")
+	writeTree(t, tmpDir, map[string]string{"org.gitignore": "*.bak\n.idea/\n"})
+
+	walked := make(map[string]bool)
+	err := WalkRepoWithIgnoreFile(root, ignoreFile, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		walked[filepath.ToSlash(rel)] = true
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkRepoWithIgnoreFile() error = %v", err)
+	}
+	assertWalked(t, walked,
+		[]string{"main.go", "keep.bak", "sub", "sub/y.go"},
+		[]string{"old.bak", ".idea", ".idea/workspace", "sub/x.bak"},
+	)
+
+	err = WalkRepoWithIgnoreFile(root, filepath.Join(tmpDir, "missing"), func(string, os.FileInfo, error) error {
+		return nil
+	})
+	if !os.IsNotExist(err) {
+		t.Errorf("WalkRepoWithIgnoreFile() with a missing ignore file error = %v, want not-exist", err)
+	}
+}
+
+func TestWithBaseIgnoreFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTree(t, tmpDir, map[string]string{
+		"system.gitignore": "*.bak\n*.swp\n.cache/\n",
+		"team.gitignore":   "!keep.bak\n*.swp\n!local.swp\n",
+		"repo/.gitignore":  "!.cache/\n",
+		"repo/main.go":     "content",
+		"repo/old.bak":     "content",
+		"repo/keep.bak":    "content",
+		"repo/x.swp":       "content",
+		"repo/local.swp":   "content",
+		"repo/.cache/a":    "content",
+	})
+	root := filepath.Join(tmpDir, "repo")
+	system := filepath.Join(tmpDir, "system.gitignore")
+	team := filepath.Join(tmpDir, "team.gitignore")
+	missing := filepath.Join(tmpDir, "missing.gitignore")
+
+	// The team's file overrides the system's, and the repository's own
+	// overrides both.
+	walked := walkedPaths(t, root, WithBaseIgnoreFiles(missing, system, team))
+	assertWalked(t, walked,
+		[]string{"main.go", "keep.bak", "local.swp", ".cache", ".cache/a"},
+		[]string{"old.bak", "x.swp"},
+	)
+
+	// In the other order, the system's file overrides the team's.
+	walked = walkedPaths(t, root, WithBaseIgnoreFiles(team, system))
+	assertWalked(t, walked,
+		[]string{"main.go", ".cache/a"},
+		[]string{"old.bak", "keep.bak", "x.swp", "local.swp"},
+	)
+
+	// The repository's .git/info/exclude overrides them all.
+	repo := filepath.Join(tmpDir, "withgit")
+	writeTree(t, repo, map[string]string{
+		".git/info/exclude": "!keep.bak\n",
+		"old.bak":           "content",
+		"keep.bak":          "content",
+	})
+	walked = walkedPaths(t, repo, WithBaseIgnoreFiles(system, team), WithInfoExclude(true), WithSkipGit(true))
+	assertWalked(t, walked, []string{"keep.bak"}, []string{"old.bak"})
+	walked = walkedPaths(t, repo, WithBaseIgnoreFiles(team, system), WithInfoExclude(true), WithSkipGit(true))
+	assertWalked(t, walked, []string{"keep.bak"}, []string{"old.bak"})
+}
+
+func TestWalkWithBasePatterns(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTree(t, tmpDir, map[string]string{
+		"org.gitignore":        "*.bak\n/.idea/\n",
+		"one/.gitignore":       "!keep.bak",
+		"one/main.go":          "content",
+		"one/old.bak":          "content",
+		"one/keep.bak":         "content",
+		"one/.idea/workspace":  "content",
+		"one/scratch.tmp":      "content",
+		"two/lib.go":           "content",
+		"two/keep.bak":         "content",
+		"two/sub/.idea/config": "content",
+	})
+	sps, err := ReadPatterns(filepath.Join(tmpDir, "org.gitignore"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := make([]gitignore.Pattern, 0, len(sps)+1)
+	for _, sp := range sps {
+		base = append(base, sp)
+	}
+	base = append(base, gitignore.ParsePattern("*.tmp", nil))
+
+	walk := func(root string) map[string]bool {
+		walked := make(map[string]bool)
+		err := WalkWithBasePatterns(root, base, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, _ := filepath.Rel(root, path)
+			walked[filepath.ToSlash(rel)] = true
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("WalkWithBasePatterns() error = %v", err)
+		}
+		return walked
+	}
+
+	assertWalked(t, walk(filepath.Join(tmpDir, "one")),
+		[]string{"main.go", "keep.bak"},
+		[]string{"old.bak", ".idea", ".idea/workspace", "scratch.tmp"},
+	)
+	assertWalked(t, walk(filepath.Join(tmpDir, "two")),
+		[]string{"lib.go", "sub", "sub/.idea", "sub/.idea/config"},
+		[]string{"keep.bak"},
+	)
+}
+
+func TestWalkRepoWithIgnoreFileAnchoring(t *testing.T) {
+	tmpDir := t.TempDir()
+	root := filepath.Join(tmpDir, "repo")
+	writeTree(t, root, map[string]string{
+		"build/out.txt":     "content",
+		"sub/build/out.txt": "content",
+		"sub/docs/a/b.md":   "content",
+		"docs/a/b.md":       "content",
+	})
+	// External patterns behave as though in the root .gitignore, however far
+	// the file itself lies from the root.
+	ignoreFile := filepath.Join(tmpDir, "shared", "ignore")
+	writeTree(t, tmpDir, map[string]string{"shared/ignore": "/build\ndocs/a\n"})
+
+	walked := make(map[string]bool)
+	err := WalkRepoWithIgnoreFile(root, ignoreFile, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		walked[filepath.ToSlash(rel)] = true
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkRepoWithIgnoreFile() error = %v", err)
+	}
+	assertWalked(t, walked,
+		[]string{"sub/build", "sub/build/out.txt", "sub/docs/a/b.md", "docs"},
+		[]string{"build", "build/out.txt", "docs/a", "docs/a/b.md"},
+	)
+}
+
+func TestWalkRepoIgnoreFileLineEndings(t *testing.T) {
+	tests := []struct {
+		name      string
+		gitignore string
+	}{
+		{"LF, no final newline", "*.tmp\n*.log"},
+		{"LF, final newline", "*.tmp\n*.log\n"},
+		{"CRLF, no final newline", "*.tmp\r\n*.log"},
+		{"CRLF, final newline", "*.tmp\r\n*.log\r\n"},
+		{"CRLF, final CR only", "*.tmp\r\n*.log\r"},
+		{"CRLF, trailing spaces", "*.tmp  \r\n*.log \r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeTree(t, root, map[string]string{
+				".gitignore": tt.gitignore,
+				"a.tmp":      "content",
+				"b.log":      "content",
+				"c.txt":      "content",
+			})
+			walked := walkedPaths(t, root)
+			assertWalked(t, walked, []string{"c.txt"}, []string{"a.tmp", "b.log"})
+		})
+	}
+}
+
+func TestWalkRepoSymlinkedIgnoreFile(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"shared/ignore":  "*.log\n",
+		"linked/a.log":   "content",
+		"linked/a.txt":   "content",
+		"dirlink/b.log":  "content",
+		"dangling/c.log": "content",
+		"emptydir/d.log": "content",
+		"emptydir/.keep": "",
+	})
+	links := map[string]string{
+		"linked/.gitignore":   filepath.Join("..", "shared", "ignore"),
+		"dirlink/.gitignore":  filepath.Join("..", "shared"),
+		"dangling/.gitignore": "missing",
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, filepath.Join(root, link)); err != nil {
+			t.Skipf("symlinks unsupported: %v", err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(root, "emptydir", ".gitignore"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	walked := walkedPaths(t, root)
+	assertWalked(t, walked,
+		[]string{"linked/a.txt", "dirlink/b.log", "dangling/c.log", "emptydir/d.log"},
+		[]string{"linked/a.log"},
+	)
+}
+
+func TestWalkRepoDir(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":    "*.log\n",
+		"a.txt":         "12345",
+		"a.log":         "content",
+		"sub/b.txt":     "123",
+		"skip/c.txt":    "content",
+		"sub/deep/d.md": "content",
+	})
+	if err := os.Symlink("a.txt", filepath.Join(root, "link")); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+
+	entries := make(map[string]fs.DirEntry)
+	err := WalkRepoDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		entries[filepath.ToSlash(rel)] = d
+		if d.IsDir() && d.Name() == "skip" {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkRepoDir() error = %v", err)
+	}
+
+	walked := make(map[string]bool)
+	for path := range entries {
+		walked[path] = true
+	}
+	assertWalked(t, walked,
+		[]string{"a.txt", "link", "sub", "sub/b.txt", "sub/deep", "sub/deep/d.md", "skip"},
+		[]string{"a.log", ".gitignore", "skip/c.txt"},
+	)
+
+	for path, wantType := range map[string]fs.FileMode{"a.txt": 0, "sub": fs.ModeDir, "link": fs.ModeSymlink} {
+		if got := entries[path].Type(); got != wantType {
+			t.Errorf("%s has type %v, want %v", path, got, wantType)
+		}
+	}
+
+	// Info is available on demand, describing the entry without following
+	// symlinks.
+	for path, wantSize := range map[string]int64{"a.txt": 5, "sub/b.txt": 3} {
+		info, err := entries[path].Info()
+		if err != nil {
+			t.Fatalf("Info() for %s error = %v", path, err)
+		}
+		if info.Size() != wantSize || info.Name() != filepath.Base(path) {
+			t.Errorf("Info() for %s = %s of %d bytes, want %d bytes", path, info.Name(), info.Size(), wantSize)
+		}
+	}
+	if info, err := entries["link"].Info(); err != nil || info.Mode()&fs.ModeSymlink == 0 {
+		t.Errorf("Info() for link = %v, %v, want a symlink", info, err)
+	}
+}
+
+func TestWalkRepoCommentLines(t *testing.T) {
+	root := t.TempDir()
+	// Only the first two lines are comments. The rest are patterns which
+	// happen to contain a "#".
+	writeTree(t, root, map[string]string{
+		".gitignore": "#comment\n# keep\n  #spaced\n\t#tabbed\n\\#hash\n",
+		"#comment":   "content",
+		"keep":       "content",
+		"# keep":     "content",
+		"  #spaced":  "content",
+		"\t#tabbed":  "content",
+		"#hash":      "content",
+	})
+
+	walked := walkedPaths(t, root)
+	assertWalked(t, walked,
+		[]string{"#comment", "keep", "# keep"},
+		[]string{"  #spaced", "\t#tabbed", "#hash"},
+	)
+}
+
+func TestWalkSubtree(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":                  "*.log\n/pkg/a/vendor/\n",
+		"top.txt":                     "content",
+		"pkg/.gitignore":              "*.tmp\nignored/\n",
+		"pkg/b/other.txt":             "content",
+		"pkg/a/.gitignore":            "!keep.log\n",
+		"pkg/a/main.go":               "content",
+		"pkg/a/debug.log":             "content",
+		"pkg/a/keep.log":              "content",
+		"pkg/a/cache.tmp":             "content",
+		"pkg/a/vendor/dep.go":         "content",
+		"pkg/a/internal/x.go":         "content",
+		"pkg/a/internal/x.log":        "content",
+		"pkg/ignored/.keep":           "content",
+		"pkg/ignored/nested/file.txt": "content",
+	})
+
+	walk := func(subPath string) (map[string]bool, error) {
+		walked := make(map[string]bool)
+		err := WalkSubtree(root, subPath, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, _ := filepath.Rel(root, path)
+			walked[filepath.ToSlash(rel)] = true
+			return nil
+		})
+		return walked, err
+	}
+
+	for _, subPath := range []string{filepath.Join("pkg", "a"), filepath.Join(root, "pkg", "a")} {
+		walked, err := walk(subPath)
+		if err != nil {
+			t.Fatalf("WalkSubtree(%q) error = %v", subPath, err)
+		}
+		// The ancestors' rules apply beneath pkg/a, and pkg/a's own may
+		// override them.
+		want := map[string]bool{
+			"pkg/a/main.go":       true,
+			"pkg/a/keep.log":      true,
+			"pkg/a/internal":      true,
+			"pkg/a/internal/x.go": true,
+		}
+		if !reflect.DeepEqual(walked, want) {
+			t.Errorf("WalkSubtree(%q) walked %v, want %v", subPath, walked, want)
+		}
+	}
+
+	// Nothing is walked within an ignored directory.
+	walked, err := walk(filepath.Join("pkg", "ignored", "nested"))
+	if err != nil || len(walked) != 0 {
+		t.Errorf("WalkSubtree() of an ignored directory walked %v, error %v", walked, err)
+	}
+
+	if _, err := walk("missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("WalkSubtree() of a missing directory error = %v, want not-exist", err)
+	}
+	if _, err := walk(".."); err == nil {
+		t.Errorf("WalkSubtree() above the root succeeded")
+	}
+}
+
+func TestWalkRepoListingOrderIndependence(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":          "*.log\n!important.log\nbuild/\n!build/\n/build/*\n!/build/keep/\n",
+		"a.log":               "content",
+		"important.log":       "content",
+		"build/out.bin":       "content",
+		"build/keep/x.bin":    "content",
+		"src/.gitignore":      "gen/\n!gen/\ngen/*\n!gen/api.go\n",
+		"src/main.go":         "content",
+		"src/gen/api.go":      "content",
+		"src/gen/other.go":    "content",
+		"src/build/nested.go": "content",
+		"src/debug.log":       "content",
+		"src/z/important.log": "content",
+	})
+
+	want := walkedPaths(t, root, WithDirSort(true))
+	assertWalked(t, want,
+		[]string{"important.log", "build", "build/keep/x.bin", "src/gen/api.go", "src/build/nested.go", "src/z/important.log"},
+		[]string{"a.log", "build/out.bin", "src/gen/other.go", "src/debug.log"},
+	)
+
+	// List each directory in a different shuffled order on every walk, with
+	// and without sorting the listings afterwards.
+	orig := readDir
+	t.Cleanup(func() { readDir = orig })
+	for seed := int64(0); seed < 20; seed++ {
+		rnd := rand.New(rand.NewSource(seed))
+		readDir = func(path string) ([]fs.DirEntry, error) {
+			files, err := orig(path)
+			rnd.Shuffle(len(files), func(i, j int) { files[i], files[j] = files[j], files[i] })
+			return files, err
+		}
+		for _, sorted := range []bool{false, true} {
+			got := walkedPaths(t, root, WithDirSort(sorted))
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("seed %d, sorted %v: walked %v, want %v", seed, sorted, got, want)
+			}
+		}
+	}
+}
+
+func TestWalkRepoSymlinkedRoot(t *testing.T) {
+	tmpDir := t.TempDir()
+	target := filepath.Join(tmpDir, "real")
+	writeTree(t, target, map[string]string{
+		".gitignore":    "*.log\n",
+		"a.txt":         "content",
+		"a.log":         "content",
+		"sub/b.txt":     "content",
+		"sub/inner.txt": "content",
+	})
+	link := filepath.Join(tmpDir, "link")
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+	if err := os.Symlink("inner.txt", filepath.Join(target, "sub", "alias")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, opts := range [][]Option{nil, {WithFollowInternalSymlinks(true)}} {
+		var got []string
+		err := WalkRepo(link, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(link, path)
+			if err != nil || strings.HasPrefix(rel, "..") {
+				t.Errorf("path %q is not beneath the symlinked root", path)
+			}
+			got = append(got, filepath.ToSlash(rel))
+			return nil
+		}, opts...)
+		if err != nil {
+			t.Fatalf("WalkRepo() error = %v", err)
+		}
+		sort.Strings(got)
+		want := []string{"a.txt", "sub", "sub/alias", "sub/b.txt", "sub/inner.txt"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("walked %q, want %q", got, want)
+		}
+	}
+
+	// Resolution is available on request.
+	realTarget, err := filepath.EvalSymlinks(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = WalkRepoEntries(link, func(e Entry) error {
+		if !strings.HasPrefix(e.Path, link+string(filepath.Separator)) {
+			t.Errorf("path %q is not beneath the symlinked root", e.Path)
+		}
+		if !strings.HasPrefix(e.RealPath, realTarget+string(filepath.Separator)) {
+			t.Errorf("RealPath %q is not beneath the resolved root", e.RealPath)
+		}
+		return nil
+	}, WithResolvePaths(true))
+	if err != nil {
+		t.Fatalf("WalkRepoEntries() error = %v", err)
 	}
 }
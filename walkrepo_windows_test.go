@@ -0,0 +1,77 @@
+package walkrepo
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWalkRepoForwardSlashRoot(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTree(t, tmpDir, map[string]string{
+		".gitignore":         "/top.txt\nsub/*.log",
+		"top.txt":            "content",
+		"keep.txt":           "content",
+		"sub/top.txt":        "content",
+		"sub/debug.log":      "content",
+		"sub/deep/other.txt": "content",
+	})
+	root := filepath.ToSlash(tmpDir)
+	if root == tmpDir {
+		t.Fatalf("temp dir %q has no backslashes to convert", tmpDir)
+	}
+
+	walked := make(map[string]bool)
+	err := WalkRepo(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.Contains(path, "/") {
+			t.Errorf("path %q is not in native form", path)
+		}
+		rel, err := filepath.Rel(tmpDir, path)
+		if err != nil {
+			return err
+		}
+		walked[filepath.ToSlash(rel)] = true
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkRepo() error = %v", err)
+	}
+	assertWalked(t, walked,
+		[]string{"keep.txt", "sub", "sub/top.txt", "sub/deep", "sub/deep/other.txt"},
+		[]string{"top.txt", "sub/debug.log"},
+	)
+}
+
+func TestWalkRepoForwardSlashRelativeTo(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTree(t, tmpDir, map[string]string{
+		"repo/.gitignore": "*.log",
+		"repo/sub/a.txt":  "content",
+		"repo/sub/a.log":  "content",
+	})
+	root := filepath.ToSlash(filepath.Join(tmpDir, "repo"))
+
+	walked := make(map[string]bool)
+	err := WalkRepo(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		walked[path] = true
+		return nil
+	}, WithRelativeTo(filepath.ToSlash(tmpDir)))
+	if err != nil {
+		t.Fatalf("WalkRepo() error = %v", err)
+	}
+	for _, expected := range []string{`repo\sub`, `repo\sub\a.txt`} {
+		if !walked[expected] {
+			t.Errorf("expected path %q was not walked (walked %v)", expected, walked)
+		}
+	}
+	if walked[`repo\sub\a.log`] {
+		t.Errorf("ignored path was walked")
+	}
+}